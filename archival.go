@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*    modo "archive": video + info.json, descripción, miniaturas, subs y      */
+/*    comentarios, con registro de la versión de yt-dlp y las opciones        */
+/* -------------------------------------------------------------------------- */
+
+const archiveRecordFile = "archive.json"
+
+/* qué se descargó, con qué y cómo: para poder reproducirlo */
+type archiveRecord struct {
+	URL       string     `json:"url"`
+	YtDlp     string     `json:"yt_dlp_version"`
+	Options   jobOptions `json:"options"`
+	Args      []string   `json:"args"` // línea de comandos exacta de yt-dlp (sin cookies)
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+/* video y archivo completo comparten formato, calidad y verificación */
+func (o jobOptions) isVideo() bool {
+	return o.Media == "video" || o.Media == "archive"
+}
+
+/* todo lo que yt-dlp sabe escribir junto al video */
+func archivalArgs(o jobOptions) []string {
+	langs := "all,-live_chat"
+	if len(o.SubLangs) > 0 {
+		langs = strings.Join(o.SubLangs, ",")
+	}
+	args := append(videoArgs(o),
+		"--write-info-json", "--no-clean-info-json",
+		"--write-description",
+		"--write-all-thumbnails",
+		"--write-comments",
+		"--write-subs", "--sub-langs", langs)
+	if o.AutoSubs {
+		args = append(args, "--write-auto-subs")
+	}
+	return args
+}
+
+func ytDlpVersion() string {
+	out, err := exec.Command("yt-dlp", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(out))
+}
+
+/* el archivo de cookies es temporal y privado: no se registra */
+func recordedArgs(args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--cookies" {
+			i++
+			continue
+		}
+		out = append(out, args[i])
+	}
+	return out
+}
+
+/* escribe archive.json en dest (entra en el manifest como "info") */
+func writeArchiveRecord(dest, url string, opts jobOptions, args []string) error {
+	rec := archiveRecord{
+		URL:       url,
+		YtDlp:     ytDlpVersion(),
+		Options:   opts,
+		Args:      recordedArgs(args),
+		CreatedAt: time.Now().UTC(),
+	}
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dest, archiveRecordFile), b, 0644)
+}
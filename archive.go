@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*            GET /download/:id?archive=zip|tar.gz  (stream al vuelo)         */
+/* -------------------------------------------------------------------------- */
+
+var archiveKinds = map[string]string{
+	"zip":    "application/zip",
+	"tar.gz": "application/gzip",
+}
+
+/* archivos del job que van al paquete (sin los zip que ya los agrupan) */
+func archiveEntries(id string) (title string, files []outputFile, err error) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	if !ok || !j.Ready {
+		return "", nil, errors.New("archivo no disponible")
+	}
+	for _, f := range j.Files {
+		if f.Role != "archive" {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return "", nil, errors.New("archivo no disponible")
+	}
+	title = j.Title
+	if title == "" {
+		title = id
+	}
+	return title, files, nil
+}
+
+/* zip sin compresión (el vídeo ya está comprimido) */
+func writeZip(w io.Writer, files []outputFile, open func(outputFile) (io.ReadCloser, error)) error {
+	zw := zip.NewWriter(w)
+	if sums := checksumList(files); len(sums) > 0 {
+		hw, err := zw.CreateHeader(&zip.FileHeader{Name: sumsName, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := hw.Write(sums); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		hw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		rc, err := open(f)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(hw, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+/* ceros de tamaño n: para calcular el tamaño exacto del zip sin leer disco */
+type zeroReader struct{ n int64 }
+
+func (z *zeroReader) Read(p []byte) (int, error) {
+	if z.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > z.n {
+		p = p[:z.n]
+	}
+	clear(p)
+	z.n -= int64(len(p))
+	return len(p), nil
+}
+
+func (z *zeroReader) Close() error { return nil }
+
+type countWriter struct{ n int64 }
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+/* tamaño final del zip: mismas cabeceras, datos de relleno */
+func zipSize(files []outputFile) (int64, error) {
+	var cw countWriter
+	err := writeZip(&cw, files, func(f outputFile) (io.ReadCloser, error) {
+		return &zeroReader{n: f.Size}, nil
+	})
+	return cw.n, err
+}
+
+func writeTarGz(w io.Writer, files []outputFile) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if sums := checksumList(files); len(sums) > 0 {
+		hdr := &tar.Header{Name: sumsName, Mode: 0644, Size: int64(len(sums)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(sums); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    f.Name,
+			Mode:    0644,
+			Size:    f.Size,
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		rc, err := openOutput(f)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+/*
+escribe el paquete directamente en la respuesta. status != 0 indica un
+error antes de enviar nada; con status 0 la respuesta ya está en curso.
+El paquete no respeta Range: cada petición cuenta como una descarga del
+enlace de r
+*/
+func streamJobArchive(w http.ResponseWriter, r *http.Request, id, kind string) (int, error) {
+	ctype, ok := archiveKinds[kind]
+	if !ok {
+		return http.StatusBadRequest, fmt.Errorf("archive debe ser zip o tar.gz")
+	}
+	title, files, err := archiveEntries(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if status, err := useShare(id, r, -1); status != 0 {
+		return status, err
+	}
+
+	name := sanitizeFilename(title) + "." + kind
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", contentDisposition(name))
+
+	if kind == "zip" {
+		if size, err := zipSize(files); err == nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+		err = writeZip(w, files, openOutput)
+	} else {
+		w.WriteHeader(http.StatusOK)
+		err = writeTarGz(w, files)
+	}
+	return 0, err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                        formatos y bitrates de audio                        */
+/* -------------------------------------------------------------------------- */
+
+/* formato pedido → códec que lo cumple sin transcodificar ("" = siempre transcodifica) */
+var audioFormats = map[string]string{
+	"original": "*",
+	"m4a":      "mp4a",
+	"opus":     "opus",
+	"mp3":      "",
+	"flac":     "",
+	"wav":      "",
+}
+
+/* orden estable para /info y la UI */
+var audioFormatOrder = []string{"original", "m4a", "opus", "mp3", "flac", "wav"}
+
+/* extensiones que puede producir cada formato */
+func audioExts(format string) []string {
+	if format == "original" {
+		return []string{"m4a", "opus", "webm", "ogg", "mp3"}
+	}
+	return []string{format}
+}
+
+type audioChoice struct {
+	Format    string `json:"format"`
+	Transcode bool   `json:"transcode"` // true si requiere re-codificar
+}
+
+/* qué formatos se pueden entregar sin transcodificar con las fuentes disponibles */
+func audioChoices(acodecs []string) []audioChoice {
+	out := make([]audioChoice, 0, len(audioFormatOrder))
+	for _, f := range audioFormatOrder {
+		codec := audioFormats[f]
+		copyOK := codec == "*"
+		for _, ac := range acodecs {
+			if codec != "" && strings.HasPrefix(ac, codec) {
+				copyOK = true
+			}
+		}
+		out = append(out, audioChoice{Format: f, Transcode: !copyOK})
+	}
+	return out
+}
+
+/* bitrate "192" / "192k" (CBR) o VBR 0 (mejor) – 10 (peor) */
+func parseAudioQuality(bitrate, vbr string) (string, error) {
+	if bitrate != "" && vbr != "" {
+		return "", fmt.Errorf("bitrate y VBR son excluyentes")
+	}
+	if bitrate != "" {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(bitrate), "k"))
+		if err != nil || n < 8 || n > 512 {
+			return "", fmt.Errorf("bitrate de audio inválido: %s", bitrate)
+		}
+		return strconv.Itoa(n) + "K", nil
+	}
+	if vbr != "" {
+		n, err := strconv.Atoi(vbr)
+		if err != nil || n < 0 || n > 10 {
+			return "", fmt.Errorf("VBR de audio inválido: %s (0-10)", vbr)
+		}
+		return strconv.Itoa(n), nil
+	}
+	return "", nil
+}
+
+/*
+argumentos de yt-dlp para un job de audio. Se prefiere una fuente que ya
+esté en el códec pedido; yt-dlp solo copia el stream en ese caso
+*/
+func audioArgs(opts jobOptions) []string {
+	src := "bestaudio"
+	if opts.Quality != "" {
+		// quality = bitrate de la fuente (audio_qualities de /info)
+		src = fmt.Sprintf("bestaudio[abr<=%s]", opts.Quality)
+	}
+	format := src + "/bestaudio/best"
+	switch codec := audioFormats[opts.AudioFormat]; codec {
+	case "*", "":
+	default:
+		format = fmt.Sprintf("%s[acodec^=%s]/%s", src, codec, format)
+	}
+
+	args := []string{"-f", format, "-x"}
+	if opts.AudioFormat != "original" {
+		args = append(args, "--audio-format", opts.AudioFormat)
+	}
+	if opts.AudioQuality != "" {
+		args = append(args, "--audio-quality", opts.AudioQuality)
+	}
+	return args
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* -------------------------------------------------------------------------- */
+/*         token de API (servidor gin): emitir enlaces y tareas de admin      */
+/* -------------------------------------------------------------------------- */
+
+/* con PocketBase se usa su propia autenticación (apis.RequireAuth) */
+
+const apiTokenFile = "api.token"
+
+var apiToken string
+
+/*
+API_TOKEN o, si no existe, uno aleatorio guardado en data/api.token
+(se avisa en el log de dónde leerlo)
+*/
+func initAuth() error {
+	if t := os.Getenv("API_TOKEN"); t != "" {
+		apiToken = t
+		return nil
+	}
+	path := filepath.Join(dataDir, apiTokenFile)
+	if b, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(b))) > 0 {
+		apiToken = strings.TrimSpace(string(b))
+		return nil
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	apiToken = hex.EncodeToString(b)
+	if err := os.WriteFile(path, []byte(apiToken+"\n"), 0600); err != nil {
+		return err
+	}
+	log.Printf("token de API generado en %s", path)
+	return nil
+}
+
+/* "Authorization: Bearer <token>" (o el token solo, como lo manda PocketBase) */
+func requestToken(r *http.Request) string {
+	h := strings.TrimSpace(r.Header.Get("Authorization"))
+	if t, ok := strings.CutPrefix(h, "Bearer "); ok {
+		return strings.TrimSpace(t)
+	}
+	return h
+}
+
+func authorized(r *http.Request) bool {
+	t := requestToken(r)
+	return apiToken != "" && t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(apiToken)) == 1
+}
+
+/* middleware gin: 401 sin el token de API */
+func requireAuthGin(c *gin.Context) {
+	if !authorized(c.Request) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "se requiere el token de API"})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                  empaquetado de varios archivos de un job                  */
+/* -------------------------------------------------------------------------- */
+
+/* un solo archivo se entrega tal cual; varios se empaquetan en dir/zipName */
+func bundleFiles(dir string, files []string, zipName string) (string, error) {
+	if len(files) == 1 {
+		return files[0], nil
+	}
+	zipPath := filepath.Join(dir, zipName)
+	if err := zipFiles(zipPath, files); err != nil {
+		return "", err
+	}
+	return zipPath, nil
+}
+
+/* crea dst con files en la raíz del zip */
+func zipFiles(dst string, files []string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, f := range files {
+		if err := addToZip(zw, f); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func addToZip(zw *zip.Writer, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	w, err := zw.Create(filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
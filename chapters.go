@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                      capítulos y split por capítulo                        */
+/* -------------------------------------------------------------------------- */
+
+const (
+	chaptersDir      = "chapters"
+	chaptersManifest = "manifest.json"
+
+	// yt-dlp rellena section_number / section_title por cada capítulo
+	chapterTmpl = "%(section_number)03d_%(section_title)s.%(ext)s"
+)
+
+/* capítulo tal como viene en el JSON de yt-dlp */
+type ytChapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+}
+
+/* capítulo expuesto por /info */
+type chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"` // segundos
+	End   float64 `json:"end"`
+}
+
+/* entrada del manifest.json que acompaña a los capítulos */
+type chapterFile struct {
+	Number int     `json:"number"`
+	Title  string  `json:"title"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	File   string  `json:"file"`
+	Size   int64   `json:"size"`
+}
+
+func toChapters(in []ytChapter) []chapter {
+	out := make([]chapter, 0, len(in))
+	for _, c := range in {
+		out = append(out, chapter{Title: c.Title, Start: c.StartTime, End: c.EndTime})
+	}
+	return out
+}
+
+var (
+	badNameChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+	spacesRe     = regexp.MustCompile(`\s+`)
+)
+
+/* nombre de archivo seguro en cualquier sistema (sin reservados ni control) */
+func sanitizeFilename(name string) string {
+	name = badNameChars.ReplaceAllString(name, "_")
+	name = spacesRe.ReplaceAllString(name, " ")
+	name = strings.Trim(name, " .")
+	if name == "" {
+		name = "archivo"
+	}
+	return name
+}
+
+/* lee el primer *.info.json que yt-dlp dejó en dir */
+func readInfoJSON(dir string, v any) error {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.info.json"))
+	if len(matches) == 0 {
+		return errors.New("info.json no encontrado")
+	}
+	b, err := os.ReadFile(matches[0])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+/*
+renombra los capítulos a "NNN_Titulo.ext" saneado, escribe manifest.json
+y empaqueta todo en Titulo_chapters.zip
+*/
+func collectChapters(dest string) (string, error) {
+	var info struct {
+		Title    string      `json:"title"`
+		Chapters []ytChapter `json:"chapters"`
+	}
+	if err := readInfoJSON(dest, &info); err != nil {
+		return "", err
+	}
+	if len(info.Chapters) == 0 {
+		return "", errors.New("el video no tiene capítulos")
+	}
+
+	dir := filepath.Join(dest, chaptersDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("capítulos: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var (
+		manifest []chapterFile
+		files    []string
+	)
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == chaptersManifest {
+			continue
+		}
+		// "007_Titulo.mp4" → 7
+		num, err := strconv.Atoi(strings.SplitN(e.Name(), "_", 2)[0])
+		if err != nil || num < 1 || num > len(info.Chapters) {
+			continue
+		}
+		ch := info.Chapters[num-1]
+
+		name := fmt.Sprintf("%03d_%s%s", num, sanitizeFilename(ch.Title), filepath.Ext(e.Name()))
+		path := filepath.Join(dir, name)
+		if err := os.Rename(filepath.Join(dir, e.Name()), path); err != nil {
+			return "", err
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		manifest = append(manifest, chapterFile{
+			Number: num,
+			Title:  ch.Title,
+			Start:  ch.StartTime,
+			End:    ch.EndTime,
+			File:   name,
+			Size:   st.Size(),
+		})
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return "", errors.New("yt-dlp no generó archivos por capítulo")
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	manifestPath := filepath.Join(dir, chaptersManifest)
+	if err := os.WriteFile(manifestPath, b, 0644); err != nil {
+		return "", err
+	}
+
+	zipPath := filepath.Join(dest, sanitizeFilename(info.Title)+"_chapters.zip")
+	if err := zipFiles(zipPath, append(files, manifestPath)); err != nil {
+		return "", err
+	}
+	return zipPath, nil
+}
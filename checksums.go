@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"lukechampine.com/blake3"
+)
+
+/* -------------------------------------------------------------------------- */
+/*            sumas de integridad (SHA-256 y, opcional, BLAKE3)               */
+/* -------------------------------------------------------------------------- */
+
+const sumsName = "sha256sums"
+
+/* CHECKSUM_BLAKE3=1 añade BLAKE3 a cada archivo (lo fija initChecksums) */
+var hashBlake3 bool
+
+func initChecksums() {
+	hashBlake3 = formBool(os.Getenv("CHECKSUM_BLAKE3"))
+}
+
+/* lee r una sola vez calculando las dos sumas */
+func hashReader(r io.Reader, withBlake3 bool) (sha, b3 string, err error) {
+	hs := sha256.New()
+	var hb hash.Hash
+	w := io.Writer(hs)
+	if withBlake3 {
+		hb = blake3.New(32, nil)
+		w = io.MultiWriter(hs, hb)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return "", "", err
+	}
+	sha = hex.EncodeToString(hs.Sum(nil))
+	if hb != nil {
+		b3 = hex.EncodeToString(hb.Sum(nil))
+	}
+	return sha, b3, nil
+}
+
+/* rellena SHA256/BLAKE3 de cada archivo a partir de la copia local */
+func hashOutputs(files []outputFile) error {
+	for i := range files {
+		f, err := os.Open(files[i].Path)
+		if err != nil {
+			return err
+		}
+		sha, b3, err := hashReader(f, hashBlake3)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", files[i].Name, err)
+		}
+		files[i].SHA256, files[i].BLAKE3 = sha, b3
+	}
+	return nil
+}
+
+/* contenido de sha256sums en formato de sha256sum(1): "<hex>  <nombre>" */
+func checksumList(files []outputFile) []byte {
+	var b bytes.Buffer
+	for _, f := range files {
+		if f.SHA256 != "" {
+			fmt.Fprintf(&b, "%s  %s\n", f.SHA256, f.Name)
+		}
+	}
+	return b.Bytes()
+}
+
+/* resultado de volver a calcular las sumas de un archivo guardado */
+type fileCheck struct {
+	Name           string `json:"name"`
+	Expected       string `json:"expected"` // SHA-256
+	Actual         string `json:"actual,omitempty"`
+	ExpectedBLAKE3 string `json:"expected_blake3,omitempty"` // si se guardó
+	ActualBLAKE3   string `json:"actual_blake3,omitempty"`
+	OK             bool   `json:"ok"`
+	Error          string `json:"error,omitempty"`
+}
+
+type verifyResp struct {
+	ID    string      `json:"id"`
+	OK    bool        `json:"ok"` // false si algún archivo cambió o falta
+	Files []fileCheck `json:"files"`
+}
+
+/*
+relee cada archivo desde el Storage y lo compara con todas las sumas
+guardadas; tras un reinicio el job ya no está en memoria y se usan las
+de la biblioteca
+*/
+func verifyJobChecksums(id string) (verifyResp, error) {
+	var files []outputFile
+	if v, ok := getJobView(id); ok {
+		files = v.Files
+	} else if files = libraryJobOutputs(id); len(files) == 0 {
+		return verifyResp{}, fmt.Errorf("job no encontrado")
+	}
+	resp := verifyResp{ID: id, OK: true, Files: []fileCheck{}}
+	for _, f := range files {
+		if f.SHA256 == "" {
+			continue
+		}
+		fc := fileCheck{Name: f.Name, Expected: f.SHA256, ExpectedBLAKE3: f.BLAKE3}
+		if rc, err := openOutput(f); err != nil {
+			fc.Error = err.Error()
+		} else {
+			fc.Actual, fc.ActualBLAKE3, err = hashReader(rc, f.BLAKE3 != "")
+			rc.Close()
+			if err != nil {
+				fc.Error = err.Error()
+			}
+		}
+		fc.OK = fc.Error == "" && fc.Actual == fc.Expected && fc.ActualBLAKE3 == fc.ExpectedBLAKE3
+		if !fc.OK {
+			resp.OK = false
+		}
+		resp.Files = append(resp.Files, fc)
+	}
+	return resp, nil
+}
+
+/* -------------------------  /jobs/:id/verify POST ------------------------- */
+
+func verifyJobGin(c *gin.Context) {
+	resp, err := verifyJobChecksums(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
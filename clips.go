@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                       recorte por rangos de tiempo                         */
+/* -------------------------------------------------------------------------- */
+
+/* yt-dlp formatea section_start / section_end como duración */
+const clipTmpl = "%(title)s_%(section_start>%H-%M-%S)s_%(section_end>%H-%M-%S)s.%(ext)s"
+
+/* rango a recortar: por tiempos (Start/End en segundos) o por capítulo */
+type clipRange struct {
+	Start   float64 `json:"start,omitempty"`
+	End     float64 `json:"end,omitempty"` // 0 = hasta el final
+	Chapter string  `json:"chapter,omitempty"`
+}
+
+/* "90" | "1:30" | "01:02:03.5" → segundos */
+func parseTimestamp(s string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("marca de tiempo inválida: %q", s)
+	}
+	var secs float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("marca de tiempo inválida: %q", s)
+		}
+		secs = secs*60 + v
+	}
+	return secs, nil
+}
+
+/*
+lista separada por comas o saltos de línea:
+
+	1:00-1:30, 1:02:00-inf, chapter:Introducción
+*/
+func parseRanges(s string) ([]clipRange, error) {
+	var out []clipRange
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(item, "chapter:"); ok {
+			if name = strings.TrimSpace(name); name == "" {
+				return nil, errors.New("rango de capítulo vacío")
+			}
+			out = append(out, clipRange{Chapter: name})
+			continue
+		}
+
+		from, to, ok := strings.Cut(item, "-")
+		if !ok {
+			return nil, fmt.Errorf("rango inválido: %q (inicio-fin)", item)
+		}
+		start, err := parseTimestamp(from)
+		if err != nil {
+			return nil, err
+		}
+		var end float64
+		if to = strings.TrimSpace(to); to != "" && to != "inf" {
+			if end, err = parseTimestamp(to); err != nil {
+				return nil, err
+			}
+			if end <= start {
+				return nil, fmt.Errorf("rango inválido: %q (fin <= inicio)", item)
+			}
+		}
+		out = append(out, clipRange{Start: start, End: end})
+	}
+	return out, nil
+}
+
+/* valor para --download-sections */
+func (r clipRange) section() string {
+	if r.Chapter != "" {
+		// regex de yt-dlp sobre el título del capítulo: coincidencia exacta
+		return "(?i)^" + regexp.QuoteMeta(r.Chapter) + "$"
+	}
+	end := "inf"
+	if r.End > 0 {
+		end = strconv.FormatFloat(r.End, 'f', -1, 64)
+	}
+	return "*" + strconv.FormatFloat(r.Start, 'f', -1, 64) + "-" + end
+}
+
+func clipArgs(ranges []clipRange, accurate bool) []string {
+	var args []string
+	for _, r := range ranges {
+		args = append(args, "--download-sections", r.section())
+	}
+	if accurate {
+		// recorta en el frame exacto (re-codifica alrededor de los cortes)
+		args = append(args, "--force-keyframes-at-cuts")
+	}
+	return args
+}
+
+/* recoge los clips generados (exts del medio) y los empaqueta si hay varios */
+func collectClips(dest string, exts []string) (string, error) {
+	var files []string
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(e.Name())), ".")
+		if slices.Contains(exts, ext) {
+			files = append(files, filepath.Join(dest, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return "", errors.New("yt-dlp no generó ningún clip")
+	}
+	sort.Strings(files)
+
+	name := filepath.Base(files[0])
+	if i := strings.LastIndex(name, "_"); i > 0 {
+		name = name[:i] // quita el fin del rango
+		if i = strings.LastIndex(name, "_"); i > 0 {
+			name = name[:i] // y el inicio
+		}
+	}
+	return bundleFiles(dest, files, name+"_clips.zip")
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*            comentarios: yt-dlp --write-comments → JSON y CSV               */
+/* -------------------------------------------------------------------------- */
+
+const maxCommentsLimit = 100000
+
+var commentSorts = map[string]bool{"top": true, "new": true}
+
+/* comentario tal como lo deja yt-dlp en el info.json */
+type ytComment struct {
+	ID               string `json:"id"`
+	Parent           string `json:"parent"` // "root" en los de primer nivel
+	Text             string `json:"text"`
+	LikeCount        int64  `json:"like_count"`
+	Author           string `json:"author"`
+	AuthorID         string `json:"author_id"`
+	Timestamp        int64  `json:"timestamp"`
+	IsPinned         bool   `json:"is_pinned"`
+	AuthorIsUploader bool   `json:"author_is_uploader"`
+}
+
+/* comentario normalizado que se entrega */
+type comment struct {
+	VideoID    string `json:"video_id"`
+	ID         string `json:"id"`
+	Parent     string `json:"parent,omitempty"` // id del comentario al que responde
+	Author     string `json:"author"`
+	AuthorID   string `json:"author_id,omitempty"`
+	Text       string `json:"text"`
+	Likes      int64  `json:"likes"`
+	Timestamp  string `json:"timestamp,omitempty"` // RFC 3339, UTC
+	Pinned     bool   `json:"pinned,omitempty"`
+	IsUploader bool   `json:"is_uploader,omitempty"`
+}
+
+var commentColumns = []string{"video_id", "id", "parent", "author", "author_id",
+	"text", "likes", "timestamp", "pinned", "is_uploader"}
+
+/* --extractor-args de YouTube; otros sitios ignoran los límites */
+func commentsArgs(o jobOptions) []string {
+	var ea []string
+	if o.MaxComments > 0 {
+		ea = append(ea, "max_comments="+strconv.Itoa(o.MaxComments))
+	}
+	if o.CommentSort != "" {
+		ea = append(ea, "comment_sort="+o.CommentSort)
+	}
+	args := []string{"--skip-download", "--write-comments", "--write-info-json",
+		"--no-write-playlist-metafiles"}
+	if len(ea) > 0 {
+		args = append(args, "--extractor-args", "youtube:"+strings.Join(ea, ";"))
+	}
+	return args
+}
+
+func normalizeComment(videoID string, c ytComment) comment {
+	n := comment{
+		VideoID:    videoID,
+		ID:         c.ID,
+		Author:     c.Author,
+		AuthorID:   c.AuthorID,
+		Text:       c.Text,
+		Likes:      c.LikeCount,
+		Pinned:     c.IsPinned,
+		IsUploader: c.AuthorIsUploader,
+	}
+	if c.Parent != "root" {
+		n.Parent = c.Parent
+	}
+	if c.Timestamp > 0 {
+		n.Timestamp = time.Unix(c.Timestamp, 0).UTC().Format(time.RFC3339)
+	}
+	return n
+}
+
+func writeCommentsJSON(path string, list []comment) error {
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+/*
+celdas que una hoja de cálculo tomaría por fórmula (=, +, -, @, tab, CR)
+llevan un ' delante; el JSON conserva el texto tal cual
+*/
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func writeCommentsCSV(path string, list []comment) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.Write(commentColumns); err != nil {
+		return err
+	}
+	for _, c := range list {
+		row := []string{c.VideoID, c.ID, c.Parent, csvCell(c.Author), c.AuthorID, csvCell(c.Text),
+			strconv.FormatInt(c.Likes, 10), c.Timestamp,
+			strconv.FormatBool(c.Pinned), strconv.FormatBool(c.IsUploader)}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+/*
+convierte cada Titulo_comments.info.json de dir en Titulo_comments.json y
+.csv, borra el info.json (puede pesar mucho) y devuelve el primer JSON.
+El info.json de la playlist no trae comentarios y se descarta
+*/
+func collectComments(dir string) (string, error) {
+	infos, _ := filepath.Glob(filepath.Join(dir, "*.info.json"))
+	sort.Strings(infos)
+	if len(infos) == 0 {
+		return "", errors.New("yt-dlp no devolvió información del video")
+	}
+
+	first := ""
+	for _, p := range infos {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		var info struct {
+			Type     string       `json:"_type"`
+			ID       string       `json:"id"`
+			Comments *[]ytComment `json:"comments"`
+		}
+		if err := json.Unmarshal(b, &info); err != nil {
+			return "", fmt.Errorf("%s: %v", filepath.Base(p), err)
+		}
+		if info.Type == "playlist" || info.Comments == nil {
+			os.Remove(p)
+			continue
+		}
+
+		list := make([]comment, 0, len(*info.Comments))
+		for _, c := range *info.Comments {
+			list = append(list, normalizeComment(info.ID, c))
+		}
+		base := strings.TrimSuffix(p, ".info.json")
+		if err := writeCommentsJSON(base+".json", list); err != nil {
+			return "", err
+		}
+		if err := writeCommentsCSV(base+".csv", list); err != nil {
+			return "", err
+		}
+		os.Remove(p)
+		if first == "" {
+			first = base + ".json"
+		}
+	}
+	if first == "" {
+		return "", errors.New("este sitio no ofrece comentarios")
+	}
+	return first, nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*      deduplicación: mismo video + mismas opciones = mismo job / archivo    */
+/* -------------------------------------------------------------------------- */
+
+const blobsFile = "blobs.json"
+
+var (
+	/* clave de petición → job que la atiende (en curso o terminado) */
+	dedupJobs = map[string]string{}
+	dedupMu   sync.Mutex
+
+	/* URL → clave averiguada con yt-dlp ("" = no tiene) */
+	mediaKeys   = map[string]string{}
+	mediaKeysMu sync.Mutex
+
+	/* sha256 → clave en el Storage de la primera copia guardada */
+	blobs   = map[string]string{}
+	blobsMu sync.Mutex
+)
+
+var ytIDRe = regexp.MustCompile(`(?:youtube\.com/(?:watch\?(?:.*&)?v=|shorts/|live/|embed/)|youtu\.be/)([A-Za-z0-9_-]{11})`)
+
+func initDedup() error {
+	blobsMu.Lock()
+	defer blobsMu.Unlock()
+	return loadJSON(blobsFile, &blobs)
+}
+
+/*
+"<extractor> <id>" del contenido de url, en el formato de
+--download-archive. YouTube se resuelve sin red y lo que ya preguntó
+probeMediaKey queda en memoria; known=false si hay que preguntar
+*/
+func knownMediaKey(url string) (key string, known bool) {
+	if m := ytIDRe.FindStringSubmatch(url); m != nil && !strings.Contains(url, "list=") {
+		return "youtube " + m[1], true
+	}
+	mediaKeysMu.Lock()
+	defer mediaKeysMu.Unlock()
+	key, known = mediaKeys[url]
+	return key, known
+}
+
+/*
+pregunta a yt-dlp (lento: se llama desde el worker); "" si no se pudo.
+Las playlists y canales tampoco tienen clave: su contenido cambia y una
+petición nueva tiene que volver a listarlo
+*/
+func probeMediaKey(url string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "yt-dlp", "-J", "--flat-playlist",
+		"--playlist-end", "1", "--no-warnings", url).Output()
+	if err != nil {
+		return "" // no se recuerda: puede ser un fallo pasajero
+	}
+	var info struct {
+		Type         string `json:"_type"`
+		ID           string `json:"id"`
+		ExtractorKey string `json:"extractor_key"`
+	}
+	key := ""
+	if json.Unmarshal(out, &info) == nil && info.ID != "" && info.Type != "playlist" {
+		key = archiveKey(flatEntry{ID: info.ID, IEKey: info.ExtractorKey})
+	}
+	mediaKeysMu.Lock()
+	mediaKeys[url] = key
+	mediaKeysMu.Unlock()
+	return key
+}
+
+/* solo los campos que cambian el resultado para este tipo de descarga */
+func (o jobOptions) normalized() jobOptions {
+	n := jobOptions{Media: o.Media, Profile: o.Profile}
+	switch o.Media {
+	case "video", "audio", "archive":
+		n.Quality = o.Quality
+		n.SplitChapters = o.SplitChapters
+		n.EmbedMetadata, n.EmbedThumbnail, n.EmbedChapters = o.EmbedMetadata, o.EmbedThumbnail, o.EmbedChapters
+		n.Ranges, n.AccurateCut = o.Ranges, o.AccurateCut
+		if o.isVideo() {
+			n.Container, n.VideoCodec, n.MaxFPS, n.HDR = o.Container, o.VideoCodec, o.MaxFPS, o.HDR
+			n.EmbedSubs = o.EmbedSubs
+		} else {
+			n.AudioFormat, n.AudioQuality = o.AudioFormat, o.AudioQuality
+		}
+	}
+	if o.Media == "transcript" {
+		n.Timestamps, n.ChapterHeadings = o.Timestamps, o.ChapterHeadings
+		n.SubLangs = append([]string(nil), o.SubLangs...)
+		sort.Strings(n.SubLangs)
+		n.AutoSubs = o.AutoSubs
+	}
+	if o.Media == "comments" {
+		n.MaxComments, n.CommentSort = o.MaxComments, o.CommentSort
+	}
+	if o.Media == "subs" || o.Media == "archive" || n.EmbedSubs {
+		n.SubLangs = append([]string(nil), o.SubLangs...)
+		sort.Strings(n.SubLangs)
+		n.SubFormat, n.AutoSubs = o.SubFormat, o.AutoSubs
+		if o.Media == "subs" {
+			n.SubShift = o.SubShift
+		}
+	}
+	return n
+}
+
+/* con perfil y sin force, ¿ya está en su archivo de descargas? */
+func archivedIn(opts jobOptions, mk string) bool {
+	return mk != "" && opts.Profile != "" && !opts.Force && profileHas(opts.Profile, mk)
+}
+
+/* clave de deduplicación; "" si no se deduplica */
+func dedupKey(mk, rawCookies string, opts jobOptions) string {
+	if mk == "" || rawCookies != "" || opts.Force { // con cookies el contenido puede ser privado
+		return ""
+	}
+	return requestKey(mk, opts)
+}
+
+/* clave de una petición: contenido ("<extractor> <id>") + opciones normalizadas */
+func requestKey(content string, opts jobOptions) string {
+	b, _ := json.Marshal(opts.normalized())
+	sum := sha256.Sum256([]byte(content + "\n" + string(b)))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+job existente para la misma petición: en curso o terminado sin error.
+Los fallidos, cancelados o cuyos archivos ya no están se olvidan para
+que se pueda descargar de nuevo. Se llama sin dedupMu: comprobar los
+archivos en un Storage remoto puede tardar y no debe frenar al resto
+*/
+func reusableJob(key string) (string, bool) {
+	dedupMu.Lock()
+	id, ok := dedupJobs[key]
+	dedupMu.Unlock()
+	if !ok {
+		return "", false
+	}
+	ready, failed, exists := jobStatus(id)
+	if exists && !failed && (!ready || jobOutputsPresent(id)) {
+		return id, true
+	}
+	dedupMu.Lock()
+	if dedupJobs[key] == id { // otra petición puede haberla reclamado ya
+		delete(dedupJobs, key)
+	}
+	dedupMu.Unlock()
+	return "", false
+}
+
+/*
+job que atiende la petición key: uno reutilizable o, si no lo hay, el
+que devuelve register, que se llama con dedupMu para que dos peticiones
+iguales no lancen dos descargas
+*/
+func claimJob(key string, register func() string) (id string, reused bool) {
+	for {
+		if id, ok := reusableJob(key); ok {
+			return id, true
+		}
+		dedupMu.Lock()
+		if _, taken := dedupJobs[key]; !taken {
+			id = register()
+			dedupJobs[key] = id
+			dedupMu.Unlock()
+			return id, false
+		}
+		dedupMu.Unlock() // la reclamó otra mientras tanto: se vuelve a mirar
+	}
+}
+
+/* ¿siguen guardados todos los archivos del job (en disco o en el Storage)? */
+func jobOutputsPresent(id string) bool {
+	jobsMu.RLock()
+	files := append([]outputFile(nil), jobs[id].Files...)
+	jobsMu.RUnlock()
+
+	rs, remote := storage.(remoteStorage)
+	for _, f := range files {
+		if _, err := os.Stat(f.Path); err == nil {
+			continue
+		}
+		if !remote {
+			return false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		exists, err := rs.Exists(ctx, f.Key)
+		cancel()
+		if err == nil && !exists {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+identifica en el worker una URL sin clave conocida: descarta lo que ya
+está en el archivo del perfil y, si otra petición igual ya tiene job,
+sigue a ese en vez de descargar de nuevo. false = el job ya terminó
+*/
+func resolveJob(id, url, rawCookies string, opts jobOptions) bool {
+	setJobStage(id, "Identificando…")
+	mk := probeMediaKey(url)
+	if jobCanceled(id) {
+		return false
+	}
+	if archivedIn(opts, mk) {
+		finishJob(id, "", errArchived)
+		return false
+	}
+	key := dedupKey(mk, rawCookies, opts)
+	if key == "" {
+		return true
+	}
+	self := func() string { return id }
+	other, reused := claimJob(key, self)
+	if !reused || other == id {
+		return true
+	}
+	if followJob(id, other) {
+		return false
+	}
+	/* el otro falló o se canceló: se descarga aparte */
+	claimJob(key, self)
+	return true
+}
+
+/*
+espera a que termine other y copia su resultado en id (los archivos son
+los mismos). false si other falla o se cancela
+*/
+func followJob(id, other string) bool {
+	setJobStage(id, "Esperando a una descarga igual…")
+	for {
+		time.Sleep(time.Second)
+		jobsMu.Lock()
+		j, src := jobs[id], jobs[other]
+		switch {
+		case j == nil || j.Canceled:
+			jobsMu.Unlock()
+			return true
+		case src == nil || src.Canceled || src.Err != "":
+			jobsMu.Unlock()
+			return false
+		case src.Ready:
+			j.Files = append([]outputFile(nil), src.Files...)
+			j.Embedded = append([]string(nil), src.Embedded...)
+			j.FilePath, j.Title = src.FilePath, src.Title
+			j.Percent, j.Stage, j.Ready = 100, "Completado ✔", true
+			jobsMu.Unlock()
+			return true
+		}
+		j.Percent = src.Percent
+		jobsMu.Unlock()
+	}
+}
+
+/* ------------------------- archivos idénticos ----------------------------- */
+
+/*
+cada archivo cuyo contenido ya estaba guardado por otro job pasa a
+apuntar a esa copia: en local se sustituye por un enlace duro, en
+remoto se reutiliza la clave y no se vuelve a subir. Solo se reutiliza
+una copia que sigue existiendo; las que faltan se olvidan. Las copias
+nuevas las apunta recordBlobs cuando ya están guardadas
+*/
+func dedupFiles(files []outputFile) {
+	rs, remote := storage.(remoteStorage)
+
+	blobsMu.Lock()
+	defer blobsMu.Unlock()
+	changed := false
+	for i := range files {
+		f := &files[i]
+		key, ok := blobs[f.SHA256]
+		if f.SHA256 == "" || !ok || key == f.Key {
+			continue
+		}
+		if remote {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			exists, err := rs.Exists(ctx, key)
+			cancel()
+			switch {
+			case err != nil: // no se sabe: se sube aparte sin olvidar la copia
+				log.Printf("blobs %s: %v", key, err)
+			case exists:
+				f.Key = key
+			default:
+				delete(blobs, f.SHA256)
+				changed = true
+			}
+			continue
+		}
+		src := localStorage{root: downloadDir}.path(key)
+		st, err := os.Stat(src)
+		if err != nil || st.Size() != f.Size {
+			delete(blobs, f.SHA256)
+			changed = true
+			continue
+		}
+		tmp := f.Path + ".link"
+		if err := os.Link(src, tmp); err == nil {
+			if err := os.Rename(tmp, f.Path); err != nil {
+				os.Remove(tmp)
+			}
+		}
+	}
+	if changed {
+		if err := saveJSON(blobsFile, blobs); err != nil {
+			log.Printf("blobs: %v", err)
+		}
+	}
+}
+
+/* apunta como primera copia el contenido que el job acaba de guardar */
+func recordBlobs(files []outputFile) {
+	blobsMu.Lock()
+	defer blobsMu.Unlock()
+	changed := false
+	for _, f := range files {
+		if _, ok := blobs[f.SHA256]; f.SHA256 != "" && !ok {
+			blobs[f.SHA256] = f.Key
+			changed = true
+		}
+	}
+	if changed {
+		if err := saveJSON(blobsFile, blobs); err != nil {
+			log.Printf("blobs: %v", err)
+		}
+	}
+}
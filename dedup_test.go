@@ -0,0 +1,205 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+/* añade jobs de prueba y los quita al terminar */
+func testJobs(t *testing.T, js map[string]*jobInfo) {
+	t.Helper()
+	jobsMu.Lock()
+	for id, j := range js {
+		jobs[id] = j
+	}
+	jobsMu.Unlock()
+	t.Cleanup(func() {
+		jobsMu.Lock()
+		for id := range js {
+			delete(jobs, id)
+		}
+		jobsMu.Unlock()
+	})
+}
+
+func TestDedupKey(t *testing.T) {
+	const mk = "youtube dQw4w9WgXcQ"
+	video := jobOptions{Media: "video", Quality: "720", Container: "mp4"}
+	subs := jobOptions{Media: "subs", SubLangs: []string{"es", "en"}, SubFormat: "srt"}
+
+	with := func(o jobOptions, f func(*jobOptions)) jobOptions {
+		f(&o)
+		return o
+	}
+	tests := []struct {
+		name string
+		a, b jobOptions
+		same bool
+	}{
+		{"mismas opciones", video, video, true},
+		{"opción de audio en un video", video, with(video, func(o *jobOptions) { o.AudioFormat = "mp3" }), true},
+		{"subtítulos sin incrustar en un video", video, with(video, func(o *jobOptions) { o.SubLangs = []string{"es"} }), true},
+		{"orden de idiomas", subs, with(subs, func(o *jobOptions) { o.SubLangs = []string{"en", "es"} }), true},
+		{"otra calidad", video, with(video, func(o *jobOptions) { o.Quality = "1080" }), false},
+		{"otro contenedor", video, with(video, func(o *jobOptions) { o.Container = "mkv" }), false},
+		{"otro perfil", video, with(video, func(o *jobOptions) { o.Profile = "música" }), false},
+		{"otro formato de subtítulos", subs, with(subs, func(o *jobOptions) { o.SubFormat = "vtt" }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := dedupKey(mk, "", tt.a), dedupKey(mk, "", tt.b)
+			if a == "" || b == "" {
+				t.Fatalf("clave vacía: %q, %q", a, b)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("claves iguales = %v, se esperaba %v", a == b, tt.same)
+			}
+		})
+	}
+
+	if dedupKey(mk, "", video) == dedupKey("youtube otroVideo00", "", video) {
+		t.Error("dos videos distintos con la misma clave")
+	}
+	for name, key := range map[string]string{
+		"sin clave de contenido": dedupKey("", "", video),
+		"con cookies":            dedupKey(mk, "# Netscape HTTP Cookie File", video),
+		"con force":              dedupKey(mk, "", with(video, func(o *jobOptions) { o.Force = true })),
+	} {
+		if key != "" {
+			t.Errorf("%s: clave %q, no se debería deduplicar", name, key)
+		}
+	}
+}
+
+func TestReusableJob(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(kept, []byte("datos"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(dir, "borrado.mp4")
+	testJobs(t, map[string]*jobInfo{
+		"test-running":  {},
+		"test-failed":   {Ready: true, Err: "fallo"},
+		"test-canceled": {Canceled: true},
+		"test-ready":    {Ready: true, Files: []outputFile{{Path: kept, Key: "test-ready/video.mp4"}}},
+		"test-gone":     {Ready: true, Files: []outputFile{{Path: kept}, {Path: gone, Key: "test-gone/borrado.mp4"}}},
+	})
+
+	tests := []struct {
+		job  string
+		want bool
+	}{
+		{"test-running", true},
+		{"test-failed", false},
+		{"test-canceled", false},
+		{"test-ready", true},
+		{"test-gone", false},
+		{"test-unknown", false},
+	}
+	for _, tt := range tests {
+		key := "key-" + tt.job
+		dedupMu.Lock()
+		dedupJobs[key] = tt.job
+		dedupMu.Unlock()
+		id, ok := reusableJob(key)
+		dedupMu.Lock()
+		_, remembered := dedupJobs[key]
+		delete(dedupJobs, key)
+		dedupMu.Unlock()
+		if ok != tt.want || ok && id != tt.job {
+			t.Errorf("%s: reusableJob = %q, %v; se esperaba %v", tt.job, id, ok, tt.want)
+		}
+		if remembered != tt.want {
+			t.Errorf("%s: sigue en dedupJobs = %v, se esperaba %v", tt.job, remembered, tt.want)
+		}
+	}
+}
+
+func TestClaimJob(t *testing.T) {
+	const key = "key-claim"
+	testJobs(t, map[string]*jobInfo{"test-claim-failed": {Ready: true, Err: "fallo"}})
+	dedupMu.Lock()
+	dedupJobs[key] = "test-claim-failed"
+	dedupMu.Unlock()
+	t.Cleanup(func() {
+		dedupMu.Lock()
+		delete(dedupJobs, key)
+		dedupMu.Unlock()
+	})
+
+	/* peticiones iguales a la vez: una sola registra, el resto la reutiliza */
+	var mu sync.Mutex
+	registered := 0
+	register := func() string {
+		mu.Lock()
+		registered++
+		mu.Unlock()
+		testJobs(t, map[string]*jobInfo{"test-claim-new": {}})
+		return "test-claim-new"
+	}
+	var wg sync.WaitGroup
+	ids := make([]string, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], _ = claimJob(key, register)
+		}()
+	}
+	wg.Wait()
+	if registered != 1 {
+		t.Errorf("register se llamó %d veces, se esperaba 1", registered)
+	}
+	for _, id := range ids {
+		if id != "test-claim-new" {
+			t.Errorf("claimJob = %q, se esperaba el job nuevo", id)
+		}
+	}
+}
+
+func TestCancelJobHolders(t *testing.T) {
+	const id = "test-shared"
+	testJobs(t, map[string]*jobInfo{id: {Holders: map[string]bool{"a": true, "b": true}}})
+
+	steps := []struct {
+		name     string
+		ticket   string
+		force    bool
+		err      error
+		canceled bool
+	}{
+		{"ticket ajeno", "x", false, errNotHolder, false},
+		{"suelta a", "a", false, nil, false},
+		{"a ya no lo tiene", "a", false, errNotHolder, false},
+		{"suelta b, el último", "b", false, nil, true},
+	}
+	for _, s := range steps {
+		if err := cancelJob(id, s.ticket, s.force); err != s.err {
+			t.Fatalf("%s: cancelJob = %v, se esperaba %v", s.name, err, s.err)
+		}
+		if c := jobCanceled(id); c != s.canceled {
+			t.Fatalf("%s: cancelado = %v, se esperaba %v", s.name, c, s.canceled)
+		}
+	}
+	if holdsTicket(id, "a") || holdsTicket(id, "b") {
+		t.Error("los tickets soltados siguen valiendo")
+	}
+	if err := cancelJob("test-unknown", "a", false); err != errJobNotFound {
+		t.Errorf("job inexistente: %v, se esperaba %v", err, errJobNotFound)
+	}
+}
+
+func TestCancelJobForce(t *testing.T) {
+	const id = "test-forced"
+	testJobs(t, map[string]*jobInfo{id: {Holders: map[string]bool{"a": true, "b": true}}})
+
+	if !holdsTicket(id, "a") || holdsTicket(id, "") || holdsTicket(id, "x") {
+		t.Fatal("holdsTicket no distingue los tickets del job")
+	}
+	if err := cancelJob(id, "", true); err != nil || !jobCanceled(id) {
+		t.Errorf("con el token se cancela siempre: err=%v cancelado=%v", err, jobCanceled(id))
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* -------------------------------------------------------------------------- */
+/*      archivo de descargas por perfil (--download-archive del servidor)     */
+/* -------------------------------------------------------------------------- */
+
+/*
+Cada perfil tiene dataDir/archives/<perfil>.archive con el formato de
+yt-dlp ("<extractor> <id>" por línea): con --download-archive yt-dlp
+se salta lo ya bajado, también dentro de playlists. Sin perfil en las
+opciones no se consulta ningún archivo
+*/
+
+const (
+	profilesDir    = "archives"
+	jobArchiveFile = "profile.archive" // copia privada del job en downloads/<id>
+)
+
+var (
+	profileRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	/* toda escritura del archivo de un perfil (API y fin de jobs) */
+	profileArchiveMu sync.Mutex
+	errArchived      = errors.New("ya descargado en este perfil (usa force para repetir)")
+)
+
+/* archivo con formato de --download-archive de yt-dlp: "<extractor> <id>" */
+func readArchiveFile(path string) (map[string]bool, error) {
+	set := map[string]bool{}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			set[line] = true
+		}
+	}
+	return set, sc.Err()
+}
+
+func appendArchiveFile(path, key string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, key)
+	return err
+}
+
+func validProfile(p string) error {
+	if !profileRe.MatchString(p) {
+		return fmt.Errorf("perfil inválido: %q (letras, números, - y _)", p)
+	}
+	return nil
+}
+
+func profileArchivePath(profile string) string {
+	return filepath.Join(dataDir, profilesDir, profile+".archive")
+}
+
+func profileHas(profile, key string) bool {
+	set, err := readArchiveFile(profileArchivePath(profile))
+	return err == nil && set[key]
+}
+
+/* añade las claves que falten (descargas forzadas, ediciones) */
+func addToProfile(profile string, keys []string) error {
+	profileArchiveMu.Lock()
+	defer profileArchiveMu.Unlock()
+	path := profileArchivePath(profile)
+	set, err := readArchiveFile(path)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if !set[k] {
+			if err := appendArchiveFile(path, k); err != nil {
+				return err
+			}
+			set[k] = true
+		}
+	}
+	return nil
+}
+
+/* reescribe el archivo sin las claves indicadas */
+func removeFromProfile(profile string, keys []string) error {
+	profileArchiveMu.Lock()
+	defer profileArchiveMu.Unlock()
+	path := profileArchivePath(profile)
+	set, err := readArchiveFile(path)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		delete(set, k)
+	}
+	var b strings.Builder
+	for _, k := range sortedSet(set) {
+		b.WriteString(k + "\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sortedSet(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+/*
+argumentos de yt-dlp: sin force, saltar lo que ya tiene el perfil. yt-dlp
+trabaja sobre una copia privada en dest (anota ahí lo que baja) para que
+las ediciones de la API no pisen sus escrituras; lo nuevo se pasa al
+perfil con recordProfile cuando el job termina bien
+*/
+func profileArgs(opts jobOptions, dest string) ([]string, error) {
+	if opts.Force || opts.Profile == "" {
+		return nil, nil
+	}
+	profileArchiveMu.Lock()
+	set, err := readArchiveFile(profileArchivePath(opts.Profile))
+	profileArchiveMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, k := range sortedSet(set) {
+		b.WriteString(k + "\n")
+	}
+	path := filepath.Join(dest, jobArchiveFile)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return nil, err
+	}
+	return []string{"--download-archive", path}, nil
+}
+
+/* registra en el perfil lo descargado por el job (claves de metaKeys) */
+func recordProfile(opts jobOptions, keys []string) error {
+	if opts.Profile == "" || len(keys) == 0 {
+		return nil
+	}
+	return addToProfile(opts.Profile, keys)
+}
+
+/* claves de archivo de lo que yt-dlp procesó en dest */
+func metaKeys(dest string) []string {
+	var keys []string
+	for _, m := range readMeta(dest) {
+		if m.ID != "" {
+			keys = append(keys, archiveKey(flatEntry{ID: m.ID, IEKey: m.ExtractorKey}))
+		}
+	}
+	return keys
+}
+
+/* "youtube abc, youtube def" o una por línea → claves válidas */
+func parseArchiveKeys(s string) ([]string, error) {
+	var keys []string
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return nil, fmt.Errorf("entrada inválida: %q (formato \"<extractor> <id>\")", strings.TrimSpace(line))
+		}
+		keys = append(keys, strings.ToLower(f[0])+" "+f[1])
+	}
+	return keys, nil
+}
+
+/* ------------------------------ respuestas -------------------------------- */
+
+type profileArchive struct {
+	Profile string   `json:"profile"`
+	Entries []string `json:"entries"`
+}
+
+func listProfiles() []profileArchive {
+	matches, _ := filepath.Glob(filepath.Join(dataDir, profilesDir, "*.archive"))
+	out := []profileArchive{}
+	for _, m := range matches {
+		name := strings.TrimSuffix(filepath.Base(m), ".archive")
+		set, err := readArchiveFile(m)
+		if err != nil {
+			continue
+		}
+		out = append(out, profileArchive{Profile: name, Entries: sortedSet(set)})
+	}
+	return out
+}
+
+func getProfileArchive(profile string) (profileArchive, error) {
+	if err := validProfile(profile); err != nil {
+		return profileArchive{}, err
+	}
+	set, err := readArchiveFile(profileArchivePath(profile))
+	if err != nil {
+		return profileArchive{}, err
+	}
+	return profileArchive{Profile: profile, Entries: sortedSet(set)}, nil
+}
+
+/* form: add / remove con claves "<extractor> <id>" */
+func editProfileArchive(profile string, form func(string) string) (profileArchive, error) {
+	if err := validProfile(profile); err != nil {
+		return profileArchive{}, err
+	}
+	add, err := parseArchiveKeys(form("add"))
+	if err != nil {
+		return profileArchive{}, err
+	}
+	remove, err := parseArchiveKeys(form("remove"))
+	if err != nil {
+		return profileArchive{}, err
+	}
+	if len(remove) > 0 {
+		if err := removeFromProfile(profile, remove); err != nil {
+			return profileArchive{}, err
+		}
+	}
+	if len(add) > 0 {
+		if err := addToProfile(profile, add); err != nil {
+			return profileArchive{}, err
+		}
+	}
+	return getProfileArchive(profile)
+}
+
+/* vacía el archivo del perfil: todo vuelve a descargarse */
+func clearProfileArchive(profile string) error {
+	if err := validProfile(profile); err != nil {
+		return err
+	}
+	profileArchiveMu.Lock()
+	defer profileArchiveMu.Unlock()
+	err := os.Remove(profileArchivePath(profile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+/* ----------------------  /archives GET|POST|DELETE ----------------------- */
+
+func listProfilesGin(c *gin.Context) {
+	c.JSON(http.StatusOK, listProfiles())
+}
+
+func profileArchiveGin(c *gin.Context) {
+	a, err := getProfileArchive(c.Param("profile"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func editProfileArchiveGin(c *gin.Context) {
+	a, err := editProfileArchive(c.Param("profile"), c.PostForm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func clearProfileArchiveGin(c *gin.Context) {
+	if err := clearProfileArchive(c.Param("profile")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cleared"})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*            incrustar metadatos, portada, capítulos y subtítulos            */
+/* -------------------------------------------------------------------------- */
+
+const (
+	embedMetadata  = "metadata"
+	embedThumbnail = "thumbnail"
+	embedChapters  = "chapters"
+	embedSubs      = "subtitles"
+)
+
+/* qué puede llevar cada contenedor / formato de salida */
+var embedSupport = map[string][]string{
+	// video
+	"mp4":  {embedMetadata, embedThumbnail, embedChapters, embedSubs},
+	"mkv":  {embedMetadata, embedThumbnail, embedChapters, embedSubs},
+	"webm": {embedMetadata, embedChapters, embedSubs},
+	// audio
+	"m4a":  {embedMetadata, embedThumbnail, embedChapters},
+	"mp3":  {embedMetadata, embedThumbnail, embedChapters},
+	"opus": {embedMetadata, embedThumbnail, embedChapters},
+	"flac": {embedMetadata, embedThumbnail},
+	"wav":  {embedMetadata},
+	// audio original: extensión desconocida hasta descargar
+	"original": {embedMetadata, embedThumbnail, embedChapters},
+}
+
+/* incrustaciones pedidas en orden estable */
+func (o jobOptions) embeds() []string {
+	var out []string
+	if o.EmbedMetadata {
+		out = append(out, embedMetadata)
+	}
+	if o.EmbedThumbnail {
+		out = append(out, embedThumbnail)
+	}
+	if o.EmbedChapters {
+		out = append(out, embedChapters)
+	}
+	if o.EmbedSubs {
+		out = append(out, embedSubs)
+	}
+	return out
+}
+
+/* contenedor final del job (para la tabla de soporte) */
+func (o jobOptions) outputContainer() string {
+	if o.Media == "audio" {
+		return o.AudioFormat
+	}
+	return o.Container
+}
+
+func validateEmbeds(o jobOptions) error {
+	want := o.embeds()
+	if len(want) == 0 {
+		return nil
+	}
+	if o.Media != "video" && o.Media != "audio" {
+		return fmt.Errorf("incrustar solo aplica a video o audio")
+	}
+	c := o.outputContainer()
+	for _, e := range want {
+		if !slices.Contains(embedSupport[c], e) {
+			return fmt.Errorf("%s no admite incrustar %s", c, e)
+		}
+	}
+	return nil
+}
+
+func embedArgs(o jobOptions) []string {
+	var args []string
+	if o.EmbedMetadata {
+		args = append(args, "--embed-metadata")
+	}
+	if o.EmbedThumbnail {
+		args = append(args, "--embed-thumbnail")
+	}
+	if o.EmbedChapters {
+		args = append(args, "--embed-chapters")
+	}
+	if o.EmbedSubs {
+		langs := o.SubLangs
+		if len(langs) == 0 {
+			langs = []string{"en"}
+		}
+		args = append(args, "--embed-subs", "--sub-langs", strings.Join(langs, ","))
+		if o.AutoSubs {
+			args = append(args, "--write-auto-subs")
+		}
+	}
+	return args
+}
+
+/*
+detecta en la salida de yt-dlp qué se incrustó realmente. FFmpegMetadata
+escribe metadatos y capítulos en el mismo paso sin decir si había
+capítulos: eso lo decide markEmbedded con los metadatos del video
+*/
+func embeddedFromLine(line string) []string {
+	switch {
+	case strings.HasPrefix(line, "[EmbedSubtitle] Embedding subtitles"):
+		return []string{embedSubs}
+	case strings.HasPrefix(line, "[EmbedThumbnail]") && strings.Contains(line, "thumbnail"):
+		return []string{embedThumbnail}
+	case strings.HasPrefix(line, "[Metadata] Adding"):
+		return []string{embedMetadata}
+	}
+	return nil
+}
+
+/* registra en el job lo incrustado (solo lo que se pidió) */
+func markEmbedded(id, line string) {
+	found := embeddedFromLine(line)
+	if len(found) == 0 {
+		return
+	}
+	if slices.Contains(found, embedMetadata) && hasChapters(filepath.Join(downloadDir, id), line) {
+		found = append(found, embedChapters)
+	}
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j, ok := jobs[id]
+	if !ok {
+		return
+	}
+	want := j.Opts.embeds()
+	for _, e := range found {
+		if slices.Contains(want, e) && !slices.Contains(j.Embedded, e) {
+			j.Embedded = append(j.Embedded, e)
+		}
+	}
+}
+
+/*
+el archivo de la línea `[Metadata] Adding metadata to "…"` es de un video
+con capítulos (metaFile se escribe antes de descargar). Si no se sabe de
+qué video es, basta con que alguno del job los tenga
+*/
+func hasChapters(dest, line string) bool {
+	stem := func(p string) string {
+		p = filepath.Base(p)
+		return strings.TrimSuffix(p, filepath.Ext(p))
+	}
+	file := ""
+	if i := strings.Index(line, `"`); i >= 0 {
+		file = stem(strings.Trim(line[i:], `"`))
+	}
+	metas := readMeta(dest)
+	for _, m := range metas {
+		if m.Filename != "" && stem(m.Filename) == file {
+			return len(m.Chapters) > 0
+		}
+	}
+	for _, m := range metas {
+		if len(m.Chapters) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/* lista separada por comas para el evento SSE "embedded" */
+func jobEmbedded(id string) string {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	if j, ok := jobs[id]; ok {
+		return strings.Join(j.Embedded, ",")
+	}
+	return ""
+}
+
+/* antes de reintentar: el intento nuevo vuelve a informar lo que incrusta */
+func clearJobEmbedded(id string) {
+	jobsMu.Lock()
+	if j, ok := jobs[id]; ok {
+		j.Embedded = nil
+	}
+	jobsMu.Unlock()
+}
@@ -0,0 +1,94 @@
+//go:build !pocketbase
+
+package main
+
+import (
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                                    main                                    */
+/* -------------------------------------------------------------------------- */
+
+func main() {
+	if err := initStorage(); err != nil {
+		log.Fatal(err)
+	}
+	initChecksums()
+	if err := initShares(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initAuth(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initLibrary(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initDedup(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initSearch(); err != nil {
+		log.Fatal(err)
+	}
+
+	/* yt-dl scan: indexa downloads/ en la biblioteca y sale (servidor parado) */
+	if len(os.Args) > 1 && os.Args[1] == "scan" {
+		if err := runScanCommand(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := lockServer(); err != nil {
+		log.Fatal(err)
+	}
+
+	r := gin.Default()
+
+	// template
+	tpl := template.Must(template.ParseFS(embeddedFS, "templates/*.html"))
+	r.SetHTMLTemplate(tpl)
+
+	// static
+	sub, _ := fs.Sub(embeddedFS, "static")
+	r.StaticFS("/static", http.FS(sub))
+
+	r.GET("/", root)
+	r.POST("/info", getInfoGin)
+	r.POST("/download", startDownloadGin)
+	r.POST("/cancel/:id", cancelDownloadGin)
+	r.GET("/progress/:id", progressGin)
+	r.GET("/download/:id", serveFileGin)
+	r.GET("/download/:id/*file", serveJobFileGin)
+	r.POST("/share/:id", shareGin)
+	r.GET("/jobs/:id", jobGin)
+	r.POST("/jobs/:id/verify", requireAuthGin, verifyJobGin)
+	r.GET("/library", libraryPageGin)
+	r.GET("/library/items", libraryListGin)
+	r.POST("/library/scan", requireAuthGin, scanGin)
+	r.GET("/library/items/:id", libraryItemGin)
+	r.GET("/library/items/:id/file", libraryFileGin)
+	r.GET("/search", searchGin)
+	r.POST("/search/reindex", requireAuthGin, reindexSearchGin)
+	r.GET("/archives", listProfilesGin)
+	r.GET("/archives/:profile", profileArchiveGin)
+	r.POST("/archives/:profile", requireAuthGin, editProfileArchiveGin)
+	r.DELETE("/archives/:profile", requireAuthGin, clearProfileArchiveGin)
+	r.GET("/stream", streamGin)
+	r.POST("/stream", streamGin)
+
+	r.GET("/subscriptions", listSubscriptionsGin)
+	r.POST("/subscriptions", requireAuthGin, createSubscriptionGin)
+	r.DELETE("/subscriptions/:id", requireAuthGin, deleteSubscriptionGin)
+	r.POST("/subscriptions/:id/check", requireAuthGin, checkSubscriptionGin)
+
+	startSubscriptionScheduler()
+
+	log.Println("http://localhost:9191")
+	r.Run(":9191")
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+/* -------------------------------------------------------------------------- */
+/*            biblioteca: cada archivo terminado con sus metadatos            */
+/* -------------------------------------------------------------------------- */
+
+const (
+	libraryFile = "library.json"
+	metaFile    = ".meta" // metadatos de cada video en JSON, una línea por evento
+)
+
+/* campos que yt-dlp vuelca a metaFile */
+const metaFields = "id,title,uploader,upload_date,duration,extractor,extractor_key,webpage_url,tags,thumbnail,chapters,description"
+
+type ytMeta struct {
+	ID           string      `json:"id"`
+	Title        string      `json:"title"`
+	Uploader     string      `json:"uploader"`
+	UploadDate   string      `json:"upload_date"` // AAAAMMDD
+	Duration     float64     `json:"duration"`
+	Extractor    string      `json:"extractor"`
+	ExtractorKey string      `json:"extractor_key"`
+	WebpageURL   string      `json:"webpage_url"`
+	Tags         []string    `json:"tags"`
+	Thumbnail    string      `json:"thumbnail"`
+	Chapters     []ytChapter `json:"chapters"`
+	Description  string      `json:"description"`
+	FilePath     string      `json:"filepath"` // solo en after_move
+	Filename     string      `json:"filename"` // ruta prevista del archivo (video:), también con --skip-download
+}
+
+type libraryItem struct {
+	ID         string    `json:"id"`
+	Job        string    `json:"job"`
+	Media      string    `json:"type"`
+	File       string    `json:"file"` // nombre en el manifest del job
+	Role       string    `json:"role"`
+	Size       int64     `json:"size"`
+	Mime       string    `json:"mime"`
+	Key        string    `json:"key"` // clave en el Storage
+	SHA256     string    `json:"sha256,omitempty"`
+	BLAKE3     string    `json:"blake3,omitempty"`
+	Title      string    `json:"title"`
+	Uploader   string    `json:"uploader,omitempty"`
+	UploadDate string    `json:"upload_date,omitempty"`
+	Duration   float64   `json:"duration,omitempty"`
+	Extractor  string    `json:"extractor,omitempty"`
+	VideoID    string    `json:"video_id,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Thumbnail  string    `json:"thumbnail,omitempty"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+var (
+	library   []*libraryItem
+	libraryMu sync.RWMutex
+)
+
+func initLibrary() error {
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
+	return loadJSON(libraryFile, &library)
+}
+
+/*
+metadatos por video: en la etapa "video" siempre; en after_move además
+con la ruta final (no ocurre con --skip-download)
+*/
+func metaArgs(dest string) []string {
+	path := filepath.Join(dest, metaFile)
+	return []string{
+		"--print-to-file", "video:%(.{" + metaFields + ",filename})j", path,
+		"--print-to-file", "after_move:%(.{" + metaFields + ",filepath})j", path,
+	}
+}
+
+func readMeta(dest string) []ytMeta {
+	f, err := os.Open(filepath.Join(dest, metaFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []ytMeta
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var m ytMeta
+		if json.Unmarshal(sc.Bytes(), &m) == nil && m.ID != "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func newLibraryItem(job, media string, f outputFile, m ytMeta) *libraryItem {
+	extractor := m.ExtractorKey
+	if extractor == "" {
+		extractor = m.Extractor
+	}
+	title := m.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(f.Name), filepath.Ext(f.Name))
+	}
+	return &libraryItem{
+		ID:         uuid.New().String(),
+		Job:        job,
+		Media:      media,
+		File:       f.Name,
+		Role:       f.Role,
+		Size:       f.Size,
+		Mime:       f.Mime,
+		Key:        f.Key,
+		SHA256:     f.SHA256,
+		BLAKE3:     f.BLAKE3,
+		Title:      title,
+		Uploader:   m.Uploader,
+		UploadDate: m.UploadDate,
+		Duration:   m.Duration,
+		Extractor:  extractor,
+		VideoID:    m.ID,
+		URL:        m.WebpageURL,
+		Tags:       m.Tags,
+		Thumbnail:  m.Thumbnail,
+		AddedAt:    time.Now(),
+	}
+}
+
+/*
+entradas de biblioteca de un job: una por archivo que yt-dlp movió a su
+sitio; si no hubo ninguno (subtítulos, miniaturas), una por video
+apuntando al archivo principal
+*/
+func libraryEntries(job, media, dest string, files []outputFile, primary string) []*libraryItem {
+	metas := readMeta(dest)
+	byID := map[string]ytMeta{}
+	var order []string
+	for _, m := range metas {
+		if _, ok := byID[m.ID]; !ok {
+			order = append(order, m.ID)
+		}
+		if m.FilePath == "" || byID[m.ID].ID == "" {
+			byID[m.ID] = m
+		}
+	}
+
+	var items []*libraryItem
+	seen := map[string]bool{}
+	for _, m := range metas {
+		if m.FilePath == "" {
+			continue
+		}
+		for _, f := range files {
+			if filepath.Clean(f.Path) == filepath.Clean(m.FilePath) && !seen[f.Name] {
+				seen[f.Name] = true
+				items = append(items, newLibraryItem(job, media, f, byID[m.ID]))
+			}
+		}
+	}
+	if len(items) > 0 || primary == "" {
+		return items
+	}
+
+	for _, f := range files {
+		if filepath.Clean(f.Path) != filepath.Clean(primary) {
+			continue
+		}
+		if len(order) == 0 {
+			return []*libraryItem{newLibraryItem(job, media, f, ytMeta{})}
+		}
+		for _, id := range order {
+			items = append(items, newLibraryItem(job, media, f, byID[id]))
+		}
+	}
+	return items
+}
+
+/* añade o reemplaza (mismo job y archivo) y persiste */
+func addToLibrary(items []*libraryItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	libraryMu.Lock()
+	defer libraryMu.Unlock()
+	for _, it := range items {
+		replaced := false
+		for i, old := range library {
+			if old.Job == it.Job && old.File == it.File && old.VideoID == it.VideoID {
+				it.ID, it.AddedAt = old.ID, old.AddedAt
+				library[i] = it
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			library = append(library, it)
+		}
+	}
+	return saveJSON(libraryFile, library)
+}
+
+func getLibraryItem(id string) (libraryItem, bool) {
+	libraryMu.RLock()
+	defer libraryMu.RUnlock()
+	for _, it := range library {
+		if it.ID == id {
+			return *it, true
+		}
+	}
+	return libraryItem{}, false
+}
+
+/* archivos que la biblioteca guardó de un job (sobreviven a reinicios) */
+func libraryJobOutputs(job string) []outputFile {
+	libraryMu.RLock()
+	defer libraryMu.RUnlock()
+	var out []outputFile
+	for _, it := range library {
+		if it.Job == job {
+			out = append(out, it.output())
+		}
+	}
+	return out
+}
+
+/* URL de la miniatura del primer video de un job que la tenga */
+func libraryJobThumbnail(job string) string {
+	libraryMu.RLock()
+	defer libraryMu.RUnlock()
+	for _, it := range library {
+		if it.Job == job && it.Thumbnail != "" {
+			return it.Thumbnail
+		}
+	}
+	return ""
+}
+
+func (it libraryItem) output() outputFile {
+	return outputFile{
+		Role:   it.Role,
+		Name:   it.File,
+		Size:   it.Size,
+		Mime:   it.Mime,
+		SHA256: it.SHA256,
+		BLAKE3: it.BLAKE3,
+		Path:   filepath.Join(downloadDir, filepath.FromSlash(it.Key)),
+		Key:    it.Key,
+	}
+}
+
+/* ------------------------------ búsqueda ---------------------------------- */
+
+type libraryQuery struct {
+	Q         string
+	Uploader  string
+	Extractor string
+	Media     string
+	Tag       string
+	From, To  string // AAAAMMDD
+	Sort      string // added | date | title | duration
+	Limit     int
+	Offset    int
+}
+
+/* AAAAMMDD → AAAA-MM-DD para <input type="date"> */
+func dateInput(d string) string {
+	if len(d) != 8 {
+		return ""
+	}
+	return d[:4] + "-" + d[4:6] + "-" + d[6:]
+}
+
+func (q libraryQuery) FromInput() string { return dateInput(q.From) }
+func (q libraryQuery) ToInput() string   { return dateInput(q.To) }
+
+/* acepta AAAA-MM-DD o AAAAMMDD */
+func normDate(s string) (string, error) {
+	d := strings.ReplaceAll(strings.TrimSpace(s), "-", "")
+	if d == "" {
+		return "", nil
+	}
+	if _, err := time.Parse("20060102", d); err != nil {
+		return "", fmt.Errorf("fecha inválida: %s", s)
+	}
+	return d, nil
+}
+
+func parseLibraryQuery(form func(string) string) (libraryQuery, error) {
+	q := libraryQuery{
+		Q:         strings.TrimSpace(form("q")),
+		Uploader:  form("uploader"),
+		Extractor: form("extractor"),
+		Media:     form("type"),
+		Tag:       form("tag"),
+		Sort:      form("sort"),
+		Limit:     50,
+	}
+	var err error
+	if q.From, err = normDate(form("from")); err != nil {
+		return q, err
+	}
+	if q.To, err = normDate(form("to")); err != nil {
+		return q, err
+	}
+	switch q.Sort {
+	case "":
+		q.Sort = "added"
+	case "added", "date", "title", "duration":
+	default:
+		return q, fmt.Errorf("orden desconocido: %s", q.Sort)
+	}
+	if v := form("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > 500 {
+			return q, fmt.Errorf("limit debe estar entre 1 y 500")
+		}
+	}
+	if v := form("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			return q, fmt.Errorf("offset inválido: %s", v)
+		}
+	}
+	return q, nil
+}
+
+/* todas las palabras de q en título, autor, etiquetas, extractor o id */
+func (it *libraryItem) matches(words []string) bool {
+	hay := strings.ToLower(strings.Join(append([]string{
+		it.Title, it.Uploader, it.Extractor, it.VideoID, it.File,
+	}, it.Tags...), "\n"))
+	for _, w := range words {
+		if !strings.Contains(hay, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func searchLibrary(q libraryQuery) (total int, items []libraryItem) {
+	words := strings.Fields(strings.ToLower(q.Q))
+
+	libraryMu.RLock()
+	for _, it := range library {
+		switch {
+		case !it.matches(words),
+			q.Uploader != "" && !strings.EqualFold(it.Uploader, q.Uploader),
+			q.Extractor != "" && !strings.EqualFold(it.Extractor, q.Extractor),
+			q.Media != "" && it.Media != q.Media,
+			q.Tag != "" && !hasTag(it.Tags, q.Tag),
+			q.From != "" && (it.UploadDate == "" || it.UploadDate < q.From),
+			q.To != "" && (it.UploadDate == "" || it.UploadDate > q.To):
+			continue
+		}
+		items = append(items, *it)
+	}
+	libraryMu.RUnlock()
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		switch q.Sort {
+		case "date":
+			return a.UploadDate > b.UploadDate
+		case "title":
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		case "duration":
+			return a.Duration > b.Duration
+		}
+		return a.AddedAt.After(b.AddedAt)
+	})
+
+	total = len(items)
+	if q.Offset >= total {
+		return total, nil
+	}
+	items = items[q.Offset:]
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+	}
+	return total, items
+}
+
+/* valores distintos para los filtros de la página */
+func libraryFacets() (uploaders, extractors []string) {
+	u, e := map[string]bool{}, map[string]bool{}
+	libraryMu.RLock()
+	for _, it := range library {
+		if it.Uploader != "" {
+			u[it.Uploader] = true
+		}
+		if it.Extractor != "" {
+			e[it.Extractor] = true
+		}
+	}
+	libraryMu.RUnlock()
+	keys := func(m map[string]bool) []string {
+		out := make([]string, 0, len(m))
+		for k := range m {
+			out = append(out, k)
+		}
+		sort.Strings(out)
+		return out
+	}
+	return keys(u), keys(e)
+}
+
+/* ------------------------------ respuestas -------------------------------- */
+
+/* elemento con enlace de descarga firmado y textos para la página */
+type libraryView struct {
+	libraryItem
+	Download     string `json:"download"`
+	DateText     string `json:"-"`
+	DurationText string `json:"-"`
+}
+
+/*
+signed solo para quien se autenticó: con él Download lleva un enlace
+firmado; sin él queda vacío y el cliente lo pide a POST /share/:id
+*/
+func newLibraryView(it libraryItem, prefix string, signed bool) libraryView {
+	v := libraryView{libraryItem: it}
+	if signed {
+		if q, _, err := shareLink(it.ID, shareTTL, 0); err == nil {
+			v.Download = prefix + "/library/items/" + it.ID + "/file?" + q
+		}
+	}
+	if t, err := time.Parse("20060102", it.UploadDate); err == nil {
+		v.DateText = t.Format("02/01/2006")
+	}
+	if it.Duration > 0 {
+		d := time.Duration(it.Duration) * time.Second
+		h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+		if h > 0 {
+			v.DurationText = fmt.Sprintf("%d:%02d:%02d", h, m, s)
+		} else {
+			v.DurationText = fmt.Sprintf("%d:%02d", m, s)
+		}
+	}
+	return v
+}
+
+type libraryResp struct {
+	Total int           `json:"total"`
+	Items []libraryView `json:"items"`
+}
+
+func libraryList(form func(string) string, prefix string, signed bool) (libraryResp, error) {
+	q, err := parseLibraryQuery(form)
+	if err != nil {
+		return libraryResp{}, err
+	}
+	total, items := searchLibrary(q)
+	resp := libraryResp{Total: total, Items: []libraryView{}}
+	for _, it := range items {
+		resp.Items = append(resp.Items, newLibraryView(it, prefix, signed))
+	}
+	return resp, nil
+}
+
+/* datos de templates/library.html */
+type libraryPageData struct {
+	Base       string
+	Query      libraryQuery
+	Error      string
+	Total      int
+	Items      []libraryView
+	Uploaders  []string
+	Extractors []string
+	Prev, Next string // enlaces de paginación; vacío = no hay
+}
+
+func libraryPage(form url.Values, base, prefix string) libraryPageData {
+	data := libraryPageData{Base: base}
+	data.Uploaders, data.Extractors = libraryFacets()
+	q, err := parseLibraryQuery(form.Get)
+	data.Query = q
+	if err != nil {
+		data.Error = err.Error()
+		return data
+	}
+	total, items := searchLibrary(q)
+	data.Total = total
+	for _, it := range items {
+		data.Items = append(data.Items, newLibraryView(it, prefix, false))
+	}
+
+	page := func(offset int) string {
+		p := url.Values{}
+		for k, v := range form {
+			p[k] = v
+		}
+		p.Set("offset", strconv.Itoa(offset))
+		return "./library?" + p.Encode()
+	}
+	if q.Offset > 0 {
+		data.Prev = page(max(q.Offset-q.Limit, 0))
+	}
+	if q.Offset+q.Limit < total {
+		data.Next = page(q.Offset + q.Limit)
+	}
+	return data
+}
+
+/* archivo de la biblioteca con enlace firmado (el uso lo cuenta serveShared) */
+func libraryOutput(id string, r *http.Request) (outputFile, int, error) {
+	if status, err := checkShare(id, r); status != 0 {
+		return outputFile{}, status, err
+	}
+	it, ok := getLibraryItem(id)
+	if !ok {
+		return outputFile{}, http.StatusNotFound, errors.New("elemento no encontrado")
+	}
+	return it.output(), 0, nil
+}
+
+/* ------------------------  /library  (página y API) ----------------------- */
+
+func libraryPageGin(c *gin.Context) {
+	c.HTML(http.StatusOK, "library.html", libraryPage(c.Request.URL.Query(), "/", ""))
+}
+
+func libraryListGin(c *gin.Context) {
+	resp, err := libraryList(c.Query, "", authorized(c.Request))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func libraryItemGin(c *gin.Context) {
+	it, ok := getLibraryItem(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "elemento no encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, newLibraryView(it, "", authorized(c.Request)))
+}
+
+func libraryFileGin(c *gin.Context) {
+	id := c.Param("id")
+	f, status, err := libraryOutput(id, c.Request)
+	if status == 0 {
+		status, err = serveShared(c.Writer, c.Request, id, f)
+	}
+	if status != 0 {
+		c.JSON(status, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+/* sin flock: no se puede detectar otro proceso sobre el mismo dataDir */
+func lockData(name string) (bool, error) { return true, nil }
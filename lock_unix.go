@@ -0,0 +1,33 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+/* archivos bloqueados: se sueltan solos al terminar el proceso */
+var heldLocks []*os.File
+
+/* bloqueo exclusivo de dataDir/name; false si lo tiene otro proceso */
+func lockData(name string) (bool, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(filepath.Join(dataDir, name), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, err
+	}
+	heldLocks = append(heldLocks, f)
+	return true, nil
+}
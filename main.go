@@ -5,10 +5,9 @@ import (
 	"bytes"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"io"
-	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -25,13 +24,16 @@ import (
 	"github.com/google/uuid"
 )
 
-const downloadDir = "downloads"
+const (
+	downloadDir = "downloads"
+	dataDir     = "data" // estado persistente (suscripciones, etc.)
+)
 
 /* -------------------------------------------------------------------------- */
 /*                    archivos embebidos (HTML + JS + CSS)                    */
 /* -------------------------------------------------------------------------- */
 
-//go:embed templates/index.html
+//go:embed templates/*.html
 //go:embed static/*
 var embeddedFS embed.FS
 
@@ -47,14 +49,26 @@ type jobInfo struct {
 	Err      string
 	Canceled bool
 	Ready    bool
+	Opts     jobOptions
+	Embedded []string     // lo que yt-dlp incrustó realmente
+	Files    []outputFile // manifest de todo lo producido
+	Title    string
+	URL      string
+	Issues   []string // problemas y avisos de la verificación con ffprobe
+	Retries  int
+	Holders  map[string]bool // tickets de las peticiones que comparten el job
 }
 
 type infoResp struct {
-	Title          string   `json:"title"`
-	ThumbURL       string   `json:"thumb_url"`
-	VideoQualities []string `json:"video_qualities"`
-	AudioQualities []string `json:"audio_qualities"`
-	SubLangs       []string `json:"sub_langs"`
+	Title          string        `json:"title"`
+	ThumbURL       string        `json:"thumb_url"`
+	VideoQualities []string      `json:"video_qualities"`
+	AudioQualities []string      `json:"audio_qualities"`
+	AudioFormats   []audioChoice `json:"audio_formats"`
+	Video          videoReach    `json:"video"`
+	SubLangs       []string      `json:"sub_langs"`      // subtítulos manuales
+	AutoSubLangs   []string      `json:"auto_sub_langs"` // subtítulos automáticos
+	Chapters       []chapter     `json:"chapters"`
 }
 
 var (
@@ -62,6 +76,9 @@ var (
 	jobsMu sync.RWMutex
 )
 
+/* se llama al terminar cada job, con éxito o no (p.ej. registros en PocketBase) */
+var onJobDone func(id string)
+
 /* -------------------------------------------------------------------------- */
 /*                              helpers de estado                             */
 /* -------------------------------------------------------------------------- */
@@ -82,6 +99,24 @@ func setJobStage(id, st string) {
 	jobsMu.Unlock()
 }
 
+/* estado resumido de un job: ok=false si no existe */
+func jobStatus(id string) (ready, failed, ok bool) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	if !ok {
+		return false, false, false
+	}
+	return j.Ready && j.Err == "", j.Err != "" || j.Canceled, true
+}
+
+func jobCanceled(id string) bool {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	return ok && j.Canceled
+}
+
 func finishJob(id, path string, err error) {
 	jobsMu.Lock()
 	if j, ok := jobs[id]; ok {
@@ -98,6 +133,90 @@ func finishJob(id, path string, err error) {
 	jobsMu.Unlock()
 }
 
+/*
+registra un job nuevo y lanza el worker; devuelve su id y el ticket con
+el que esta petición puede cancelarlo. Si la misma petición (mismo
+contenido y opciones, sin cookies) ya está en curso o terminada se
+devuelve ese job con reused=true. Con perfil y sin force, lo que ya está
+en su archivo de descargas devuelve errArchived. Las URLs que no son de
+YouTube se identifican en el worker (resolveJob): así la petición no
+espera a yt-dlp
+*/
+func newJob(url, rawCookies string, opts jobOptions) (id, ticket string, reused bool, err error) {
+	mk, known := knownMediaKey(url)
+	if known && archivedIn(opts, mk) {
+		return "", "", false, errArchived
+	}
+	key := dedupKey(mk, rawCookies, opts)
+
+	ticket = uuid.New().String()
+	register := func() string {
+		id := uuid.New().String()
+		jobsMu.Lock()
+		jobs[id] = &jobInfo{Opts: opts, URL: url, Holders: map[string]bool{ticket: true}}
+		jobsMu.Unlock()
+		return id
+	}
+	if key == "" {
+		id = register()
+	} else if id, reused = claimJob(key, register); reused {
+		jobsMu.Lock()
+		jobs[id].Holders[ticket] = true
+		jobsMu.Unlock()
+		return id, ticket, true, nil
+	}
+
+	go func() {
+		if known || resolveJob(id, url, rawCookies, opts) {
+			downloadJob(id, url, rawCookies, opts)
+		}
+		if onJobDone != nil {
+			onJobDone(id)
+		}
+	}()
+	return id, ticket, false, nil
+}
+
+var (
+	errJobNotFound = errors.New("job no encontrado")
+	errNotHolder   = errors.New("ticket inválido para este job")
+)
+
+/* ¿ticket es de una de las peticiones que comparten el job id? */
+func holdsTicket(id, ticket string) bool {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	return ok && ticket != "" && j.Holders[ticket]
+}
+
+/*
+suelta la petición dueña de ticket; el job solo se cancela cuando ya no
+queda ninguna que lo comparta. force (token de API) lo cancela siempre
+*/
+func cancelJob(id, ticket string, force bool) error {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	job, ok := jobs[id]
+	if !ok {
+		return errJobNotFound
+	}
+	if !force {
+		if !job.Holders[ticket] {
+			return errNotHolder
+		}
+		delete(job.Holders, ticket)
+		if len(job.Holders) > 0 {
+			return nil
+		}
+	}
+	job.Canceled = true
+	if job.Cmd != nil && job.Cmd.Process != nil {
+		_ = job.Cmd.Process.Kill()
+	}
+	return nil
+}
+
 /* -------------------------------------------------------------------------- */
 /*                   cookies: JSON → Netscape conversión                      */
 /* -------------------------------------------------------------------------- */
@@ -158,6 +277,79 @@ func prepareCookieFile(raw string, workDir string) (string, func(), error) {
 	return tmp, func() { os.Remove(tmp) }, nil
 }
 
+/* -------------------------------------------------------------------------- */
+/*                         info de yt-dlp (-J) → infoResp                     */
+/* -------------------------------------------------------------------------- */
+
+/* subconjunto del JSON de yt-dlp que nos interesa */
+type ytInfo struct {
+	Title             string `json:"title"`
+	Thumbnail         string `json:"thumbnail"`
+	Thumbnails        []struct{ URL string }
+	Formats           []ytFormat
+	Subtitles         map[string][]any `json:"subtitles"`
+	AutomaticCaptions map[string][]any `json:"automatic_captions"`
+	Chapters          []ytChapter      `json:"chapters"`
+}
+
+func sortedKeys(m map[string][]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func parseInfo(out []byte) (infoResp, error) {
+	var yt ytInfo
+	if err := json.Unmarshal(out, &yt); err != nil {
+		return infoResp{}, err
+	}
+
+	vset, aset := map[int]struct{}{}, map[string]struct{}{}
+	var acodecs []string
+	for _, f := range yt.Formats {
+		if f.Vcodec != "none" && f.Height > 0 {
+			vset[f.Height] = struct{}{}
+		}
+		if f.Acodec != "none" && f.Vcodec == "none" && f.Abr > 0 {
+			aset[fmt.Sprintf("%.0f", f.Abr)] = struct{}{}
+			acodecs = append(acodecs, f.Acodec)
+		}
+	}
+	videoQ := make([]int, 0, len(vset))
+	for h := range vset {
+		videoQ = append(videoQ, h)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(videoQ)))
+	audioQ := make([]string, 0, len(aset))
+	for a := range aset {
+		audioQ = append(audioQ, a)
+	}
+	sort.Strings(audioQ)
+
+	thumb := yt.Thumbnail
+	if len(yt.Thumbnails) > 0 {
+		thumb = yt.Thumbnails[len(yt.Thumbnails)-1].URL
+	}
+
+	resp := infoResp{
+		Title:        yt.Title,
+		ThumbURL:     thumb,
+		SubLangs:     sortedKeys(yt.Subtitles),
+		AutoSubLangs: sortedKeys(yt.AutomaticCaptions),
+		Chapters:     toChapters(yt.Chapters),
+	}
+	for _, h := range videoQ {
+		resp.VideoQualities = append(resp.VideoQualities, fmt.Sprintf("%d", h))
+	}
+	resp.AudioQualities = audioQ
+	resp.AudioFormats = audioChoices(acodecs)
+	resp.Video = videoReachability(yt.Formats)
+	return resp, nil
+}
+
 /* -------------------------------------------------------------------------- */
 /*                                  rutas                                     */
 /* -------------------------------------------------------------------------- */
@@ -197,58 +389,11 @@ func getInfoGin(c *gin.Context) {
 		return
 	}
 
-	/* parse JSON de yt-dlp (igual que antes) */
-	var yt struct {
-		Title      string `json:"title"`
-		Thumbnail  string `json:"thumbnail"`
-		Thumbnails []struct{ URL string }
-		Formats    []struct {
-			Vcodec, Acodec string
-			Height         int
-			Abr            float64
-		}
-		Subtitles map[string][]any `json:"subtitles"`
-	}
-	if err := json.Unmarshal(out, &yt); err != nil {
+	resp, err := parseInfo(out)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	vset, aset := map[int]struct{}{}, map[string]struct{}{}
-	for _, f := range yt.Formats {
-		if f.Vcodec != "none" && f.Height > 0 {
-			vset[f.Height] = struct{}{}
-		}
-		if f.Acodec != "none" && f.Vcodec == "none" && f.Abr > 0 {
-			aset[fmt.Sprintf("%.0f", f.Abr)] = struct{}{}
-		}
-	}
-	videoQ := make([]int, 0, len(vset))
-	for h := range vset {
-		videoQ = append(videoQ, h)
-	}
-	sort.Sort(sort.Reverse(sort.IntSlice(videoQ)))
-	audioQ := make([]string, 0, len(aset))
-	for a := range aset {
-		audioQ = append(audioQ, a)
-	}
-	sort.Strings(audioQ)
-	langs := make([]string, 0, len(yt.Subtitles))
-	for l := range yt.Subtitles {
-		langs = append(langs, l)
-	}
-	sort.Strings(langs)
-
-	thumb := yt.Thumbnail
-	if len(yt.Thumbnails) > 0 {
-		thumb = yt.Thumbnails[len(yt.Thumbnails)-1].URL
-	}
-
-	resp := infoResp{Title: yt.Title, ThumbURL: thumb, SubLangs: langs}
-	for _, h := range videoQ {
-		resp.VideoQualities = append(resp.VideoQualities, fmt.Sprintf("%d", h))
-	}
-	resp.AudioQualities = audioQ
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -261,38 +406,32 @@ func startDownloadGin(c *gin.Context) {
 		return
 	}
 
-	id := uuid.New().String()
-	jobsMu.Lock()
-	jobs[id] = &jobInfo{}
-	jobsMu.Unlock()
-
-	go downloadJob(
-		id,
-		url,
-		c.PostForm("cookies"), // ← único campo
-		c.DefaultPostForm("type", "video"),
-		c.PostForm("quality"),
-		c.PostForm("sub_lang"),
-	)
+	opts, err := parseJobOptions(c.PostForm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"job": id})
+	id, ticket, reused, err := newJob(url, c.PostForm("cookies"), opts)
+	if errors.Is(err, errArchived) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": id, "ticket": ticket, "reused": reused})
 }
 
 /* ---------------------------  /cancel POST -------------------------------- */
 
 func cancelDownloadGin(c *gin.Context) {
-	id := c.Param("id")
-	jobsMu.Lock()
-	defer jobsMu.Unlock()
-	if job, ok := jobs[id]; ok {
-		job.Canceled = true
-		if job.Cmd != nil && job.Cmd.Process != nil {
-			_ = job.Cmd.Process.Kill()
-		}
+	err := cancelJob(c.Param("id"), c.Request.FormValue("ticket"), authorized(c.Request))
+	switch {
+	case errors.Is(err, errJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
 		c.JSON(http.StatusOK, gin.H{"status": "canceled"})
-		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "job no encontrado"})
 }
 
 /* ---------------------------  /progress SSE ------------------------------ */
@@ -339,7 +478,14 @@ func progressGin(c *gin.Context) {
 		c.Writer.Flush()
 
 		if job.Ready {
-			fmt.Fprintf(c.Writer, "event: ready\ndata: /download/%s\n\n", id)
+			if emb := jobEmbedded(id); emb != "" {
+				fmt.Fprintf(c.Writer, "event: embedded\ndata: %s\n\n", emb)
+			}
+			if iss := jobIssues(id); iss != "" {
+				fmt.Fprintf(c.Writer, "event: issues\ndata: %s\n\n", iss)
+			}
+			/* sin enlace: el stream es público, el enlace se pide a /share con el ticket */
+			fmt.Fprintf(c.Writer, "event: ready\ndata: %s\n\n", id)
 			c.Writer.Flush()
 			return
 		}
@@ -351,14 +497,55 @@ func progressGin(c *gin.Context) {
 
 func serveFileGin(c *gin.Context) {
 	id := c.Param("id")
-	jobsMu.RLock()
-	job, ok := jobs[id]
-	jobsMu.RUnlock()
-	if !ok || job.FilePath == "" {
+	if status, err := checkShare(id, c.Request); status != 0 {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if kind := c.Query("archive"); kind != "" {
+		if status, err := streamJobArchive(c.Writer, c.Request, id, kind); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+		} else if err != nil {
+			log.Printf("archive %s: %v", id, err)
+		}
+		return
+	}
+	f, ok := jobPrimary(id)
+	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"error": "archivo no disponible"})
 		return
 	}
-	c.FileAttachment(job.FilePath, filepath.Base(job.FilePath))
+	if status, err := serveShared(c.Writer, c.Request, id, f); status != 0 {
+		c.JSON(status, gin.H{"error": err.Error()})
+	}
+}
+
+/* ------------------------  /download/:id/:file GET ------------------------ */
+
+func serveJobFileGin(c *gin.Context) {
+	id := c.Param("id")
+	if status, err := checkShare(id, c.Request); status != 0 {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	f, err := jobFile(id, strings.TrimPrefix(c.Param("file"), "/"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if status, err := serveShared(c.Writer, c.Request, id, f); status != 0 {
+		c.JSON(status, gin.H{"error": err.Error()})
+	}
+}
+
+/* ---------------------------  /jobs/:id GET ------------------------------- */
+
+func jobGin(c *gin.Context) {
+	v, ok := getJobView(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job no encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, v)
 }
 
 /* -------------------------------------------------------------------------- */
@@ -370,7 +557,9 @@ var (
 	destRe     = regexp.MustCompile(`Destination: .*\.([a-z0-9]+)`)
 )
 
-func downloadJob(id, url, rawCookies, media, quality, subLang string) {
+func downloadJob(id, url, rawCookies string, opts jobOptions) {
+	media := opts.Media
+
 	/* -------- carpeta de trabajo -------- */
 	dest := filepath.Join(downloadDir, id)
 	_ = os.MkdirAll(dest, 0755)
@@ -381,9 +570,16 @@ func downloadJob(id, url, rawCookies, media, quality, subLang string) {
 	case "audio":
 		nameTmpl = "%(title)s_audio.%(ext)s"
 	case "subs":
-		nameTmpl = "%(title)s_%(language)s.%(ext)s"
+		nameTmpl = "%(title)s.%(ext)s" // yt-dlp añade .<idioma>
 	case "thumb":
 		nameTmpl = "%(title)s_thumb.%(ext)s"
+	case "comments":
+		nameTmpl = "%(title)s_comments.%(ext)s"
+	case "transcript":
+		nameTmpl = "%(title)s.%(ext)s" // yt-dlp añade .<idioma>
+	}
+	if len(opts.Ranges) > 0 {
+		nameTmpl = clipTmpl
 	}
 	outPath := filepath.Join(dest, nameTmpl)
 
@@ -394,39 +590,72 @@ func downloadJob(id, url, rawCookies, media, quality, subLang string) {
 		"-o", outPath,
 	}
 
+	/* solo los rangos pedidos */
+	args = append(args, clipArgs(opts.Ranges, opts.AccurateCut)...)
+
+	/* un archivo por capítulo (video o audio) */
+	if opts.SplitChapters {
+		args = append(args,
+			"--split-chapters", "--write-info-json",
+			"-o", "chapter:"+filepath.Join(dest, chaptersDir, chapterTmpl))
+	}
+
 	/* flags según tipo */
 	switch media {
 	case "audio":
-		args = append(args, "-f", "bestaudio", "-x", "--audio-format", "mp3")
+		args = append(args, audioArgs(opts)...)
 		setJobStage(id, "Descargando audio…")
-		if quality != "" {
-			args = append(args, "--audio-quality", quality)
-		}
 
 	case "subs":
-		if subLang == "" {
-			subLang = "en"
+		langs := opts.SubLangs
+		if len(langs) == 0 {
+			langs = []string{"en"}
 		}
 		args = append(args,
-			"--skip-download", "--write-sub",
-			"--sub-lang", subLang, "--sub-format", "srt", "--convert-subs", "srt")
+			"--skip-download", "--write-subs",
+			"--sub-langs", strings.Join(langs, ","),
+			"--sub-format", subSourceFormats)
+		if opts.AutoSubs {
+			args = append(args, "--write-auto-subs")
+		}
 		setJobStage(id, "Descargando subtítulos…")
 
 	case "thumb":
 		args = append(args, "--skip-download", "--write-thumbnail")
 		setJobStage(id, "Descargando miniatura…")
 
+	case "transcript":
+		args = append(args, transcriptArgs(opts)...)
+		setJobStage(id, "Descargando subtítulos…")
+
+	case "comments":
+		args = append(args, commentsArgs(opts)...)
+		setJobStage(id, "Descargando comentarios…")
+
+	case "archive":
+		args = append(args, archivalArgs(opts)...)
+		setJobStage(id, "Archivando…")
+
 	default: // video
-		format := "bestvideo[ext=mp4]+bestaudio[ext=m4a]/best[ext=mp4]/best"
-		if quality != "" {
-			format = fmt.Sprintf(
-				"bestvideo[ext=mp4][height<=%s]+bestaudio[ext=m4a]"+
-					"/best[ext=mp4][height<=%s]/best", quality, quality)
-		}
-		args = append(args, "-f", format, "--merge-output-format", "mp4")
+		args = append(args, videoArgs(opts)...)
 		setJobStage(id, "Descargando video…")
 	}
 
+	/* rutas finales de cada archivo (para el manifest) */
+	args = append(args, outputsArgs(dest)...)
+	args = append(args, metaArgs(dest)...)
+
+	/* saltar lo que ya está en el archivo del perfil */
+	archived, aerr := profileArgs(opts, dest)
+	if aerr != nil {
+		finishJob(id, "", fmt.Errorf("archivo del perfil: %v", aerr))
+		return
+	}
+	args = append(args, archived...)
+
+	/* metadatos, portada, capítulos y subtítulos dentro del archivo */
+	args = append(args, embedArgs(opts)...)
+
 	/* ---------- cookies (JSON o Netscape) ---------- */
 	if rawCookies != "" {
 		cookieFile, _, err := prepareCookieFile(rawCookies, dest)
@@ -466,14 +695,62 @@ func downloadJob(id, url, rawCookies, media, quality, subLang string) {
 		return
 	}
 
-	/* localizar el .mp4 final */
-	var final string
-	filepath.WalkDir(dest, func(p string, d os.DirEntry, _ error) error {
-		if !d.IsDir() && filepath.Ext(p) == ".mp4" {
-			final = p
+	/* versión de yt-dlp y opciones exactas junto a lo archivado */
+	if media == "archive" {
+		if err := writeArchiveRecord(dest, url, opts, cmd.Args[1:]); err != nil {
+			finishJob(id, "", err)
+			return
+		}
+	}
+
+	/* archivo principal según el tipo de job */
+	var (
+		final   string
+		sources []string // subtítulos de la transcripción: se indexan y se borran
+		err     error
+	)
+	switch {
+	case opts.SplitChapters:
+		final, err = collectChapters(dest)
+
+	case len(opts.Ranges) > 0:
+		exts := []string{opts.Container}
+		if media == "audio" {
+			exts = audioExts(opts.AudioFormat)
 		}
-		return nil
-	})
+		final, err = collectClips(dest, exts)
+
+	case media == "subs": // uno o varios (zip)
+		setJobStage(id, "Convirtiendo subtítulos…")
+		if err = convertSubtitles(dest, opts); err == nil {
+			final, err = collectSubtitles(dest, opts.SubFormat)
+		}
+
+	case media == "transcript":
+		setJobStage(id, "Transcribiendo…")
+		final, sources, err = collectTranscripts(dest, opts)
+
+	case media == "comments":
+		setJobStage(id, "Convirtiendo comentarios…")
+		final, err = collectComments(dest)
+	}
+
+	/* manifest de todo lo producido */
+	files, merr := buildManifest(dest)
+	files = withoutFiles(files, sources)
+	if err == nil {
+		err = merr
+	}
+	if err == nil && final == "" {
+		final = primaryOutput(files, reportedOutputs(dest))
+		switch {
+		case final != "":
+		case len(archived) > 0:
+			err = fmt.Errorf("todo ya estaba en el archivo del perfil %s", opts.Profile)
+		default:
+			err = fmt.Errorf("yt-dlp no generó ningún archivo")
+		}
+	}
 
 	/* asegurarse de que ya no crece */
 	if final != "" {
@@ -482,10 +759,66 @@ func downloadJob(id, url, rawCookies, media, quality, subLang string) {
 		s2, _ := os.Stat(final)
 		if s1 != nil && s2 != nil && s1.Size() != s2.Size() {
 			time.Sleep(500 * time.Millisecond)
+			files, _ = buildManifest(dest) // tamaños definitivos
+			files = withoutFiles(files, sources)
 		}
 	}
 
-	finishJob(id, final, nil)
+	/* comprobar con ffprobe lo producido; reintentar si se pidió */
+	if err == nil {
+		setJobStage(id, "Verificando…")
+		issues, warnings := verifyOutputs(opts, dest, files)
+		retries := jobRetries(id)
+		if len(issues) > 0 && retries < opts.VerifyRetries {
+			log.Printf("job %s: verificación fallida, reintento %d: %s", id, retries+1, strings.Join(issues, "; "))
+			setJobIssues(id, issues, retries+1)
+			clearJobEmbedded(id)
+			setJobPercent(id, 0)
+			setJobStage(id, "Reintentando…")
+			os.RemoveAll(dest)
+			downloadJob(id, url, rawCookies, opts)
+			return
+		}
+		setJobIssues(id, append(issues, warnings...), retries)
+	}
+
+	/* sumas de integridad de la versión definitiva */
+	if err == nil {
+		setJobStage(id, "Calculando sumas…")
+		err = hashOutputs(files)
+	}
+
+	/* contenido ya guardado por otro job: una sola copia */
+	if err == nil {
+		dedupFiles(files)
+	}
+
+	setJobOutputs(id, readJobTitle(dest), files)
+	entries := libraryEntries(id, media, dest, files, final)
+	docs := searchDocs(entries, dest) // antes de que el Storage libere el disco
+	keys := metaKeys(dest)
+	for _, p := range sources {
+		os.Remove(p)
+	}
+
+	/* backend remoto: subir y liberar disco */
+	if err == nil {
+		setJobStage(id, "Guardando…")
+		err = storeOutputs(id, dest, files)
+	}
+	if err == nil {
+		recordBlobs(files)
+		if lerr := addToLibrary(entries); lerr != nil {
+			log.Printf("biblioteca %s: %v", id, lerr)
+		}
+		if serr := indexSearch(docs); serr != nil {
+			log.Printf("búsqueda %s: %v", id, serr)
+		}
+		if aerr := recordProfile(opts, keys); aerr != nil {
+			log.Printf("job %s: archivo del perfil: %v", id, aerr)
+		}
+	}
+	finishJob(id, final, err)
 }
 
 /* ------------------------------ progreso ---------------------------------- */
@@ -504,6 +837,8 @@ func parseProgress(id string, r io.Reader) {
 				setJobStage(id, "Descargando audio…")
 			}
 		}
+		markEmbedded(id, line)
+
 		if bytes.Contains([]byte(line), []byte("Merging")) ||
 			bytes.Contains([]byte(line), []byte("ffmpeg")) {
 			setJobStage(id, "Combinando (FFmpeg)…")
@@ -519,29 +854,3 @@ func parseProgress(id string, r io.Reader) {
 		}
 	}
 }
-
-/* -------------------------------------------------------------------------- */
-/*                                    main                                    */
-/* -------------------------------------------------------------------------- */
-
-func main() {
-	r := gin.Default()
-
-	// template
-	tpl := template.Must(template.ParseFS(embeddedFS, "templates/index.html"))
-	r.SetHTMLTemplate(tpl)
-
-	// static
-	sub, _ := fs.Sub(embeddedFS, "static")
-	r.StaticFS("/static", http.FS(sub))
-
-	r.GET("/", root)
-	r.POST("/info", getInfoGin)
-	r.POST("/download", startDownloadGin)
-	r.POST("/cancel/:id", cancelDownloadGin)
-	r.GET("/progress/:id", progressGin)
-	r.GET("/download/:id", serveFileGin)
-
-	log.Println("http://localhost:9191")
-	r.Run(":9191")
-}
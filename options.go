@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*          opciones de descarga (comunes a /download y suscripciones)        */
+/* -------------------------------------------------------------------------- */
+
+type jobOptions struct {
+	Media   string `json:"type"`
+	Quality string `json:"quality,omitempty"`
+
+	/* video */
+	Container  string `json:"container,omitempty"`   // mp4 | mkv | webm
+	VideoCodec string `json:"video_codec,omitempty"` // h264 | vp9 | av1 ("" = cualquiera)
+	MaxFPS     int    `json:"max_fps,omitempty"`
+	HDR        string `json:"hdr,omitempty"` // "" | prefer | avoid
+
+	/* audio */
+	AudioFormat  string `json:"audio_format,omitempty"`  // original | m4a | opus | mp3 | flac | wav
+	AudioQuality string `json:"audio_quality,omitempty"` // --audio-quality: "192K" o VBR "0"-"10"
+
+	/* subtítulos */
+	SubLangs  []string `json:"sub_langs,omitempty"`
+	SubFormat string   `json:"sub_format,omitempty"` // srt | vtt | ass
+	AutoSubs  bool     `json:"auto_subs,omitempty"`  // incluir automáticos
+	SubShift  float64  `json:"sub_shift,omitempty"`  // segundos a retrasar (negativo adelanta)
+
+	SplitChapters bool `json:"split_chapters,omitempty"`
+
+	/* incrustar en el contenedor final */
+	EmbedMetadata  bool `json:"embed_metadata,omitempty"`
+	EmbedThumbnail bool `json:"embed_thumbnail,omitempty"`
+	EmbedChapters  bool `json:"embed_chapters,omitempty"`
+	EmbedSubs      bool `json:"embed_subs,omitempty"` // usa SubLangs / AutoSubs
+
+	/* recorte: rangos de tiempo o capítulos */
+	Ranges      []clipRange `json:"ranges,omitempty"`
+	AccurateCut bool        `json:"accurate_cut,omitempty"`
+
+	/* reintentos si la verificación con ffprobe falla */
+	VerifyRetries int `json:"verify_retries,omitempty"`
+
+	/* comentarios: cuántos (0 = todos) y orden (top | new) */
+	MaxComments int    `json:"max_comments,omitempty"`
+	CommentSort string `json:"comment_sort,omitempty"`
+
+	/* transcripción: marcas de tiempo y títulos de capítulo */
+	Timestamps      bool `json:"timestamps,omitempty"`
+	ChapterHeadings bool `json:"chapter_headings,omitempty"`
+
+	/* archivo de descargas del perfil; Force descarga aunque ya esté */
+	Profile string `json:"profile,omitempty"`
+	Force   bool   `json:"force,omitempty"`
+}
+
+var subFormats = map[string]bool{"srt": true, "vtt": true, "ass": true}
+
+/* valores de type; cualquier otro se descargaría como video con otra clave */
+var mediaTypes = map[string]bool{
+	"video": true, "audio": true, "subs": true, "thumb": true,
+	"transcript": true, "comments": true, "archive": true,
+}
+
+/* desfase máximo de subtítulos, en segundos */
+const maxSubShift = 3600
+
+/* "en, es,,fr" → [en es fr] */
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+/*
+calidad pedida: altura (video) o bitrate (audio) como entero positivo.
+Va dentro de expresiones -f / -S de yt-dlp, así que no se acepta nada más
+*/
+func parseQuality(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("calidad inválida: %s", v)
+	}
+	return strconv.Itoa(n), nil
+}
+
+func formBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+/* lee las opciones de descarga de un formulario (gin o PocketBase) */
+func parseJobOptions(form func(string) string) (jobOptions, error) {
+	o := jobOptions{
+		Media: form("type"),
+
+		Container:  strings.ToLower(form("container")),
+		VideoCodec: strings.ToLower(form("video_codec")),
+		HDR:        strings.ToLower(form("hdr")),
+
+		AudioFormat: strings.ToLower(form("audio_format")),
+
+		SubLangs:  splitList(form("sub_langs")),
+		SubFormat: strings.ToLower(form("sub_format")),
+		AutoSubs:  formBool(form("auto_subs")),
+
+		SplitChapters: formBool(form("split_chapters")),
+
+		EmbedMetadata:  formBool(form("embed_metadata")),
+		EmbedThumbnail: formBool(form("embed_thumbnail")),
+		EmbedChapters:  formBool(form("embed_chapters")),
+		EmbedSubs:      formBool(form("embed_subs")),
+
+		AccurateCut: formBool(form("accurate_cut")),
+
+		Timestamps:      formBool(form("timestamps")),
+		ChapterHeadings: formBool(form("chapter_headings")),
+	}
+	if o.Media == "" {
+		o.Media = "video"
+	}
+	if !mediaTypes[o.Media] {
+		return o, fmt.Errorf("tipo no soportado: %s", o.Media)
+	}
+	q, err := parseQuality(form("quality"))
+	if err != nil {
+		return o, err
+	}
+	o.Quality = q
+
+	// compat: campo antiguo de un solo idioma
+	if len(o.SubLangs) == 0 {
+		o.SubLangs = splitList(form("sub_lang"))
+	}
+	if o.SubFormat == "" {
+		o.SubFormat = "srt"
+	}
+	if !subFormats[o.SubFormat] {
+		return o, fmt.Errorf("formato de subtítulos no soportado: %s", o.SubFormat)
+	}
+	if v := form("sub_shift"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.Abs(f) > maxSubShift {
+			return o, fmt.Errorf("sub_shift debe estar entre -%d y %d segundos", maxSubShift, maxSubShift)
+		}
+		o.SubShift = f
+	}
+	if o.Container == "" {
+		o.Container = "mp4"
+	}
+	if v := form("max_fps"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return o, fmt.Errorf("max_fps inválido: %s", v)
+		}
+		o.MaxFPS = n
+	}
+	if err := validateVideoOptions(o); err != nil {
+		return o, err
+	}
+
+	if o.AudioFormat == "" {
+		o.AudioFormat = "mp3"
+	}
+	if _, ok := audioFormats[o.AudioFormat]; !ok {
+		return o, fmt.Errorf("formato de audio no soportado: %s", o.AudioFormat)
+	}
+	aq, err := parseAudioQuality(form("audio_bitrate"), form("audio_vbr"))
+	if err != nil {
+		return o, err
+	}
+	o.AudioQuality = aq
+
+	if err := validateEmbeds(o); err != nil {
+		return o, err
+	}
+
+	if o.SplitChapters && o.Media != "video" && o.Media != "audio" {
+		return o, fmt.Errorf("split por capítulos solo para video o audio")
+	}
+
+	ranges, err := parseRanges(form("ranges"))
+	if err != nil {
+		return o, err
+	}
+	o.Ranges = ranges
+	if len(o.Ranges) > 0 {
+		if o.Media != "video" && o.Media != "audio" {
+			return o, fmt.Errorf("recorte solo para video o audio")
+		}
+		if o.SplitChapters {
+			return o, fmt.Errorf("recorte y split por capítulos son excluyentes")
+		}
+	}
+
+	if v := form("verify_retries"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxVerifyRetries {
+			return o, fmt.Errorf("verify_retries debe estar entre 0 y %d", maxVerifyRetries)
+		}
+		o.VerifyRetries = n
+	}
+
+	if v := form("max_comments"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxCommentsLimit {
+			return o, fmt.Errorf("max_comments debe estar entre 0 y %d", maxCommentsLimit)
+		}
+		o.MaxComments = n
+	}
+	if o.CommentSort = strings.ToLower(form("comment_sort")); o.CommentSort != "" && !commentSorts[o.CommentSort] {
+		return o, fmt.Errorf("orden de comentarios no soportado: %s", o.CommentSort)
+	}
+
+	if v := strings.TrimSpace(form("profile")); v != "" {
+		if err := validProfile(v); err != nil {
+			return o, err
+		}
+		o.Profile = v
+	}
+	o.Force = formBool(form("force"))
+	return o, nil
+}
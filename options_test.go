@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseJobOptionsType(t *testing.T) {
+	tests := []struct {
+		typ, quality string
+		want         string // Media resultante; "" = error
+	}{
+		{"", "", "video"},
+		{"video", "720", "video"},
+		{"audio", "", "audio"},
+		{"transcript", "", "transcript"},
+		{"archive", "", "archive"},
+		{"Video", "", ""},
+		{"foo", "", ""},
+		{"video", "720p", ""},
+		{"video", "-1", ""},
+	}
+	for _, tt := range tests {
+		form := map[string]string{"type": tt.typ, "quality": tt.quality}
+		o, err := parseJobOptions(func(k string) string { return form[k] })
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("type=%q quality=%q: se esperaba un error", tt.typ, tt.quality)
+		case tt.want != "" && (err != nil || o.Media != tt.want):
+			t.Errorf("type=%q quality=%q: %q (%v), se esperaba %q", tt.typ, tt.quality, o.Media, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                  manifest de archivos producidos por un job                */
+/* -------------------------------------------------------------------------- */
+
+const (
+	outputsFile = ".outputs" // yt-dlp escribe aquí la ruta final (tras mover) de cada archivo
+	titleFile   = ".title"   // y aquí el título de cada video
+)
+
+type outputFile struct {
+	Role   string `json:"role"` // video | audio | subtitle | transcript | thumbnail | info | chapter | archive | other
+	Name   string `json:"name"` // relativo a downloads/<id>
+	Size   int64  `json:"size"`
+	Mime   string `json:"mime"`
+	SHA256 string `json:"sha256,omitempty"`
+	BLAKE3 string `json:"blake3,omitempty"`
+	Path   string `json:"-"` // copia local
+	Key    string `json:"-"` // clave en el Storage: "<id>/<name>"
+}
+
+var roleByExt = map[string]string{
+	".mp4": "video", ".mkv": "video", ".webm": "video", ".mov": "video",
+	".m4a": "audio", ".mp3": "audio", ".opus": "audio", ".ogg": "audio",
+	".flac": "audio", ".wav": "audio", ".aac": "audio",
+	".srt": "subtitle", ".vtt": "subtitle", ".ass": "subtitle", ".lrc": "subtitle",
+	".txt": "transcript", ".md": "transcript",
+	".jpg": "thumbnail", ".jpeg": "thumbnail", ".png": "thumbnail", ".webp": "thumbnail",
+	".json": "info", ".description": "info", ".csv": "info",
+	".zip": "archive",
+}
+
+/* tipos que mime.TypeByExtension no conoce en todos los sistemas */
+var extraMimes = map[string]string{
+	".mkv":         "video/x-matroska",
+	".webm":        "video/webm",
+	".m4a":         "audio/mp4",
+	".opus":        "audio/ogg",
+	".flac":        "audio/flac",
+	".srt":         "application/x-subrip",
+	".vtt":         "text/vtt",
+	".ass":         "text/x-ssa",
+	".webp":        "image/webp",
+	".description": "text/plain",
+	".md":          "text/markdown",
+}
+
+func mimeFor(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if m, ok := extraMimes[ext]; ok {
+		return m
+	}
+	if m := mime.TypeByExtension(ext); m != "" {
+		return m
+	}
+	return "application/octet-stream"
+}
+
+/* archivos auxiliares o temporales que no se entregan */
+func internalFile(name string) bool {
+	switch {
+	case name == outputsFile, name == titleFile, name == metaFile, name == "cookies.txt",
+		name == jobArchiveFile:
+		return true
+	case strings.HasSuffix(name, ".part"), strings.HasSuffix(name, ".ytdl"),
+		strings.Contains(name, ".temp."), strings.HasSuffix(name, ".tmp"):
+		return true
+	}
+	return false
+}
+
+func outputsArgs(dest string) []string {
+	return []string{
+		"--print-to-file", "after_move:filepath", filepath.Join(dest, outputsFile),
+		"--print-to-file", "video:%(title)s", filepath.Join(dest, titleFile),
+	}
+}
+
+/* primer título informado (en playlists, el del primer video) */
+func readJobTitle(dest string) string {
+	b, err := os.ReadFile(filepath.Join(dest, titleFile))
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(b), "\n")
+	return strings.TrimSpace(line)
+}
+
+/* rutas finales informadas por yt-dlp que siguen existiendo */
+func reportedOutputs(dest string) []string {
+	f, err := os.Open(filepath.Join(dest, outputsFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		p := strings.TrimSpace(sc.Text())
+		if p == "" || p == "NA" {
+			continue
+		}
+		if _, err := os.Stat(p); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+/* recorre downloads/<id> y clasifica cada archivo entregable */
+func buildManifest(dest string) ([]outputFile, error) {
+	var files []outputFile
+	err := filepath.WalkDir(dest, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || internalFile(d.Name()) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dest, p)
+		rel = filepath.ToSlash(rel)
+
+		role, ok := roleByExt[strings.ToLower(filepath.Ext(p))]
+		switch {
+		case strings.HasPrefix(rel, chaptersDir+"/"):
+			role = "chapter"
+		case !ok:
+			role = "other"
+		}
+		files = append(files, outputFile{
+			Role: role,
+			Name: rel,
+			Size: info.Size(),
+			Mime: mimeFor(p),
+			Path: p,
+			Key:  storageKey(filepath.Base(dest), rel),
+		})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, err
+}
+
+/* files sin las rutas locales de drop */
+func withoutFiles(files []outputFile, drop []string) []outputFile {
+	if len(drop) == 0 {
+		return files
+	}
+	skip := map[string]bool{}
+	for _, p := range drop {
+		skip[p] = true
+	}
+	out := files[:0]
+	for _, f := range files {
+		if !skip[f.Path] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+/* archivo principal: lo último que movió yt-dlp o, si no, el de mayor prioridad */
+func primaryOutput(files []outputFile, reported []string) string {
+	if len(reported) > 0 {
+		return reported[len(reported)-1]
+	}
+	for _, role := range []string{"video", "audio", "archive", "subtitle", "thumbnail"} {
+		for _, f := range files {
+			if f.Role == role {
+				return f.Path
+			}
+		}
+	}
+	return ""
+}
+
+func setJobOutputs(id, title string, files []outputFile) {
+	jobsMu.Lock()
+	if j, ok := jobs[id]; ok {
+		j.Title = title
+		j.Files = files
+	}
+	jobsMu.Unlock()
+}
+
+/* ruta de un archivo del manifest por nombre */
+func jobFile(id, name string) (outputFile, error) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	if !ok {
+		return outputFile{}, errors.New("job no encontrado")
+	}
+	for _, f := range j.Files {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return outputFile{}, errors.New("archivo no disponible")
+}
+
+/* archivo principal del job como entrada del manifest */
+func jobPrimary(id string) (outputFile, bool) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	if !ok || j.FilePath == "" {
+		return outputFile{}, false
+	}
+	for _, f := range j.Files {
+		if filepath.Clean(f.Path) == filepath.Clean(j.FilePath) {
+			return f, true
+		}
+	}
+	return outputFile{}, false
+}
+
+/* estado público de un job (GET /jobs/:id) */
+type jobView struct {
+	ID       string       `json:"id"`
+	URL      string       `json:"url,omitempty"`
+	Title    string       `json:"title,omitempty"`
+	Stage    string       `json:"stage"`
+	Percent  int          `json:"percent"`
+	Ready    bool         `json:"ready"`
+	Canceled bool         `json:"canceled"`
+	Error    string       `json:"error,omitempty"`
+	File     string       `json:"file,omitempty"`
+	Files    []outputFile `json:"files"`
+	Embedded []string     `json:"embedded,omitempty"`
+	Issues   []string     `json:"issues,omitempty"`
+	Retries  int          `json:"retries,omitempty"`
+	Options  jobOptions   `json:"options"`
+}
+
+func getJobView(id string) (jobView, bool) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	if !ok {
+		return jobView{}, false
+	}
+	v := jobView{
+		ID:       id,
+		URL:      j.URL,
+		Title:    j.Title,
+		Stage:    j.Stage,
+		Percent:  j.Percent,
+		Ready:    j.Ready,
+		Canceled: j.Canceled,
+		Error:    j.Err,
+		Files:    append([]outputFile(nil), j.Files...),
+		Embedded: append([]string(nil), j.Embedded...),
+		Issues:   append([]string(nil), j.Issues...),
+		Retries:  j.Retries,
+		Options:  j.Opts,
+	}
+	if j.FilePath != "" {
+		v.File = filepath.Base(j.FilePath)
+	}
+	return v, true
+}
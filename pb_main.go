@@ -1,3 +1,5 @@
+//go:build pocketbase
+
 package main
 
 import (
@@ -6,15 +8,50 @@ import (
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/spf13/cobra"
 )
 
 func main() {
+	if err := initStorage(); err != nil {
+		log.Fatal(err)
+	}
+	initChecksums()
+	if err := initShares(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initLibrary(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initDedup(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initSearch(); err != nil {
+		log.Fatal(err)
+	}
+
 	app := pocketbase.New()
 
+	app.RootCmd.AddCommand(&cobra.Command{
+		Use:   "scan",
+		Short: "Indexa en la biblioteca los archivos de downloads/ (con el servidor parado)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScanCommand()
+		},
+	})
+
 	app.OnServe().Bind(&hook.Handler[*core.ServeEvent]{
 		Func: func(e *core.ServeEvent) error {
+			if err := lockServer(); err != nil {
+				return err
+			}
+			if err := ensureDownloadsCollection(e.App); err != nil {
+				return err
+			}
+			bindDownloadRecords(e.App)
+
 			group := e.Router.Group("/yt")
 			registerPbRoutes(e.App, group)
+			startSubscriptionScheduler()
 			return e.Next()
 		},
 	})
@@ -0,0 +1,217 @@
+//go:build pocketbase
+
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+/* -------------------------------------------------------------------------- */
+/*           colección "downloads": un registro por job terminado             */
+/* -------------------------------------------------------------------------- */
+
+const downloadsCollection = "downloads"
+
+/* el límite por defecto de PB (5 MB) no sirve para video */
+const maxRecordFile = 1 << 40
+
+func downloadsFields() []core.Field {
+	return []core.Field{
+		&core.TextField{Name: "job", Required: true},
+		&core.URLField{Name: "url"},
+		&core.TextField{Name: "title"},
+		&core.SelectField{Name: "type", MaxSelect: 1, Values: []string{"video", "audio", "subs", "thumb", "archive", "comments", "transcript"}},
+		&core.JSONField{Name: "options"},
+		&core.SelectField{Name: "status", MaxSelect: 1, Values: []string{"completed", "failed", "canceled"}},
+		&core.TextField{Name: "error"},
+		&core.FileField{Name: "file", MaxSelect: 1, MaxSize: maxRecordFile, Protected: true},
+		&core.FileField{
+			Name: "thumbnail", MaxSelect: 1, MaxSize: maxRecordFile,
+			MimeTypes: []string{"image/jpeg", "image/png", "image/webp"},
+			Thumbs:    []string{"320x180"},
+		},
+		&core.TextField{Name: "sha256"},    // del archivo principal
+		&core.JSONField{Name: "checksums"}, // nombre → sumas de cada archivo del job
+		&core.AutodateField{Name: "created", OnCreate: true},
+		&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+	}
+}
+
+/*
+crea la colección si no existe y añade los campos que falten a una ya
+creada. Sin reglas de acceso (nil) solo los superusuarios la ven; se
+pueden abrir desde la UI de administración
+*/
+func ensureDownloadsCollection(app core.App) error {
+	c, err := app.FindCollectionByNameOrId(downloadsCollection)
+	changed := err != nil
+	if changed {
+		c = core.NewBaseCollection(downloadsCollection)
+		c.AddIndex("idx_downloads_job", true, "job", "")
+	}
+	for _, f := range downloadsFields() {
+		cur := c.Fields.GetByName(f.GetName())
+		if cur == nil {
+			c.Fields.Add(f)
+			changed = true
+			continue
+		}
+		/* tipos de descarga nuevos en un select ya creado */
+		if want, ok := f.(*core.SelectField); ok {
+			if have, ok := cur.(*core.SelectField); ok {
+				for _, v := range want.Values {
+					if !slices.Contains(have.Values, v) {
+						have.Values = append(have.Values, v)
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return app.Save(c)
+}
+
+/*
+copia local de un archivo del job para PB. Con backend remoto la copia
+de downloads/ ya no existe: se baja a un temporal que el llamador borra
+*/
+func recordFile(f outputFile) (*filesystem.File, func(), error) {
+	if _, err := os.Stat(f.Path); err == nil {
+		file, err := filesystem.NewFileFromPath(f.Path)
+		return file, func() {}, err
+	}
+
+	rc, err := openOutput(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+	tmpDir, err := os.MkdirTemp("", "ytrecord_")
+	if err != nil {
+		return nil, nil, err
+	}
+	clean := func() { os.RemoveAll(tmpDir) }
+	tmp := filepath.Join(tmpDir, filepath.Base(f.Name))
+	out, err := os.Create(tmp)
+	if err == nil {
+		_, err = io.Copy(out, rc)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		clean()
+		return nil, nil, err
+	}
+	file, err := filesystem.NewFileFromPath(tmp)
+	if err != nil {
+		clean()
+		return nil, nil, err
+	}
+	return file, clean, nil
+}
+
+/* guarda el job terminado como registro; PB copia los archivos a su storage */
+func saveDownloadRecord(app core.App, id string) error {
+	v, ok := getJobView(id)
+	if !ok {
+		return nil
+	}
+	col, err := app.FindCollectionByNameOrId(downloadsCollection)
+	if err != nil {
+		return err
+	}
+
+	status := "completed"
+	switch {
+	case v.Canceled:
+		status = "canceled"
+	case v.Error != "":
+		status = "failed"
+	}
+
+	rec := core.NewRecord(col)
+	rec.Set("job", id)
+	rec.Set("url", v.URL)
+	rec.Set("title", v.Title)
+	rec.Set("type", v.Options.Media)
+	rec.Set("options", v.Options)
+	rec.Set("status", status)
+	rec.Set("error", v.Error)
+
+	sums := map[string]map[string]string{}
+	for _, f := range v.Files {
+		if f.SHA256 == "" {
+			continue
+		}
+		sums[f.Name] = map[string]string{"sha256": f.SHA256}
+		if f.BLAKE3 != "" {
+			sums[f.Name]["blake3"] = f.BLAKE3
+		}
+	}
+	rec.Set("checksums", sums)
+
+	if status == "completed" {
+		if f, ok := jobPrimary(id); ok {
+			file, clean, err := recordFile(f)
+			if err != nil {
+				return err
+			}
+			defer clean()
+			rec.Set("file", file)
+			rec.Set("sha256", f.SHA256)
+		}
+		if file, clean, ok := recordThumbnail(id, v.Files); ok {
+			defer clean()
+			rec.Set("thumbnail", file)
+		}
+	}
+	return app.Save(rec)
+}
+
+/*
+miniatura del registro: la que el job ya produjo o, si no pidió ninguna,
+la del video descargada solo para PB (no pasa por el job ni su manifest)
+*/
+func recordThumbnail(id string, files []outputFile) (*filesystem.File, func(), bool) {
+	for _, f := range files {
+		if f.Role != "thumbnail" {
+			continue
+		}
+		file, clean, err := recordFile(f)
+		return file, clean, err == nil
+	}
+	url := libraryJobThumbnail(id)
+	if url == "" {
+		return nil, nil, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	file, err := filesystem.NewFileFromURL(ctx, url)
+	if err != nil {
+		log.Printf("miniatura de %s: %v", id, err)
+		return nil, nil, false
+	}
+	return file, func() {}, true
+}
+
+/* engancha el guardado al final de cada job */
+func bindDownloadRecords(app core.App) {
+	onJobDone = func(id string) {
+		if err := saveDownloadRecord(app, id); err != nil {
+			log.Printf("registro de %s: %v", id, err)
+		}
+	}
+}
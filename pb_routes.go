@@ -1,9 +1,11 @@
+//go:build pocketbase
+
 package main
 
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -11,11 +13,8 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"sort"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/router"
@@ -23,11 +22,11 @@ import (
 
 func registerPbRoutes(app core.App, rg *router.RouterGroup[*core.RequestEvent]) {
 	// templates
-	tpl := template.Must(template.ParseFS(embeddedFS, "templates/index.html"))
+	tpl := template.Must(template.ParseFS(embeddedFS, "templates/*.html"))
 
 	rg.GET("/", func(e *core.RequestEvent) error {
 		var buf bytes.Buffer
-		if err := tpl.Execute(&buf, nil); err != nil {
+		if err := tpl.ExecuteTemplate(&buf, "index.html", nil); err != nil {
 			return err
 		}
 		return e.HTML(http.StatusOK, buf.String())
@@ -57,6 +56,145 @@ func registerPbRoutes(app core.App, rg *router.RouterGroup[*core.RequestEvent])
 	rg.GET("/download/{id}", func(e *core.RequestEvent) error {
 		return serveFilePB(e)
 	})
+
+	rg.GET("/download/{id}/{file...}", func(e *core.RequestEvent) error {
+		return serveJobFilePB(e)
+	})
+
+	rg.POST("/share/{id}", func(e *core.RequestEvent) error {
+		return sharePB(e)
+	})
+
+	rg.GET("/jobs/{id}", func(e *core.RequestEvent) error {
+		v, ok := getJobView(e.Request.PathValue("id"))
+		if !ok {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": "job no encontrado"})
+		}
+		return e.JSON(http.StatusOK, v)
+	})
+
+	rg.GET("/library", func(e *core.RequestEvent) error {
+		var buf bytes.Buffer
+		data := libraryPage(e.Request.URL.Query(), "/yt/", "/yt")
+		if err := tpl.ExecuteTemplate(&buf, "library.html", data); err != nil {
+			return err
+		}
+		return e.HTML(http.StatusOK, buf.String())
+	})
+
+	rg.GET("/library/items", func(e *core.RequestEvent) error {
+		resp, err := libraryList(e.Request.URL.Query().Get, "/yt", e.Auth != nil)
+		if err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, resp)
+	})
+
+	rg.POST("/library/scan", func(e *core.RequestEvent) error {
+		rep, err := scanDownloads()
+		if err != nil {
+			return e.JSON(scanStatus(err), map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, rep)
+	}).Bind(apis.RequireAuth())
+
+	rg.GET("/library/items/{id}", func(e *core.RequestEvent) error {
+		it, ok := getLibraryItem(e.Request.PathValue("id"))
+		if !ok {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": "elemento no encontrado"})
+		}
+		return e.JSON(http.StatusOK, newLibraryView(it, "/yt", e.Auth != nil))
+	})
+
+	rg.GET("/library/items/{id}/file", func(e *core.RequestEvent) error {
+		id := e.Request.PathValue("id")
+		f, status, err := libraryOutput(id, e.Request)
+		if status == 0 {
+			status, err = serveShared(e.Response, e.Request, id, f)
+		}
+		if status != 0 {
+			return e.JSON(status, map[string]string{"error": err.Error()})
+		}
+		return nil
+	})
+
+	rg.POST("/jobs/{id}/verify", func(e *core.RequestEvent) error {
+		resp, err := verifyJobChecksums(e.Request.PathValue("id"))
+		if err != nil {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, resp)
+	}).Bind(apis.RequireAuth())
+
+	rg.GET("/search", func(e *core.RequestEvent) error {
+		resp, err := searchText(e.Request.URL.Query().Get, "/yt", e.Auth != nil)
+		if err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, resp)
+	})
+
+	rg.POST("/search/reindex", func(e *core.RequestEvent) error {
+		n, err := reindexSearch()
+		if err != nil {
+			return e.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, map[string]int{"documents": n})
+	}).Bind(apis.RequireAuth())
+
+	rg.GET("/archives", func(e *core.RequestEvent) error {
+		return e.JSON(http.StatusOK, listProfiles())
+	})
+
+	rg.GET("/archives/{profile}", func(e *core.RequestEvent) error {
+		a, err := getProfileArchive(e.Request.PathValue("profile"))
+		if err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, a)
+	})
+
+	rg.POST("/archives/{profile}", func(e *core.RequestEvent) error {
+		a, err := editProfileArchive(e.Request.PathValue("profile"), e.Request.FormValue)
+		if err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, a)
+	}).Bind(apis.RequireAuth())
+
+	rg.DELETE("/archives/{profile}", func(e *core.RequestEvent) error {
+		if err := clearProfileArchive(e.Request.PathValue("profile")); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusOK, map[string]string{"status": "cleared"})
+	}).Bind(apis.RequireAuth())
+
+	rg.GET("/stream", func(e *core.RequestEvent) error {
+		return streamPB(e)
+	})
+
+	rg.POST("/stream", func(e *core.RequestEvent) error {
+		return streamPB(e)
+	})
+
+	rg.GET("/subscriptions", func(e *core.RequestEvent) error {
+		return e.JSON(http.StatusOK, listSubscriptions())
+	})
+
+	rg.POST("/subscriptions", func(e *core.RequestEvent) error {
+		return createSubscriptionPB(e)
+	}).Bind(apis.RequireAuth())
+
+	rg.DELETE("/subscriptions/{id}", func(e *core.RequestEvent) error {
+		if !deleteSubscription(e.Request.PathValue("id")) {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": "suscripción no encontrada"})
+		}
+		return e.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+	}).Bind(apis.RequireAuth())
+
+	rg.POST("/subscriptions/{id}/check", func(e *core.RequestEvent) error {
+		return checkSubscriptionPB(e)
+	}).Bind(apis.RequireAuth())
 }
 
 func progressPB(e *core.RequestEvent) error {
@@ -111,7 +249,14 @@ func progressPB(e *core.RequestEvent) error {
 		}
 
 		if job.Ready {
-			fmt.Fprintf(e.Response, "event: ready\ndata: /download/%s\n\n", id)
+			if emb := jobEmbedded(id); emb != "" {
+				fmt.Fprintf(e.Response, "event: embedded\ndata: %s\n\n", emb)
+			}
+			if iss := jobIssues(id); iss != "" {
+				fmt.Fprintf(e.Response, "event: issues\ndata: %s\n\n", iss)
+			}
+			/* sin enlace: el stream es público, el enlace se pide a /share con el ticket */
+			fmt.Fprintf(e.Response, "event: ready\ndata: %s\n\n", id)
 			if flusher != nil {
 				flusher.Flush()
 			}
@@ -124,14 +269,52 @@ func progressPB(e *core.RequestEvent) error {
 
 func serveFilePB(e *core.RequestEvent) error {
 	id := e.Request.PathValue("id")
-	jobsMu.RLock()
-	job, ok := jobs[id]
-	jobsMu.RUnlock()
-	if !ok || job.FilePath == "" {
+	if status, err := checkShare(id, e.Request); status != 0 {
+		return e.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if kind := e.Request.URL.Query().Get("archive"); kind != "" {
+		if status, err := streamJobArchive(e.Response, e.Request, id, kind); status != 0 {
+			return e.JSON(status, map[string]string{"error": err.Error()})
+		} else if err != nil {
+			e.App.Logger().Warn("archive", "job", id, "error", err)
+		}
+		return nil
+	}
+	f, ok := jobPrimary(id)
+	if !ok {
 		return e.JSON(http.StatusNotFound, map[string]string{"error": "archivo no disponible"})
 	}
-	e.Response.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(job.FilePath))
-	http.ServeFile(e.Response, e.Request, job.FilePath)
+	if status, err := serveShared(e.Response, e.Request, id, f); status != 0 {
+		return e.JSON(status, map[string]string{"error": err.Error()})
+	}
+	return nil
+}
+
+func serveJobFilePB(e *core.RequestEvent) error {
+	id := e.Request.PathValue("id")
+	if status, err := checkShare(id, e.Request); status != 0 {
+		return e.JSON(status, map[string]string{"error": err.Error()})
+	}
+	f, err := jobFile(id, e.Request.PathValue("file"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if status, err := serveShared(e.Response, e.Request, id, f); status != 0 {
+		return e.JSON(status, map[string]string{"error": err.Error()})
+	}
+	return nil
+}
+
+func streamPB(e *core.RequestEvent) error {
+	url := e.Request.FormValue("url")
+	if url == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "url requerida"})
+	}
+	status, err := streamMedia(e.Request.Context(), e.Response, url,
+		e.Request.FormValue("cookies"), e.Request.FormValue("type"), e.Request.FormValue("quality"))
+	if status != 0 {
+		return e.JSON(status, map[string]string{"error": err.Error()})
+	}
 	return nil
 }
 
@@ -162,94 +345,74 @@ func getInfoPB(e *core.RequestEvent) error {
 		return e.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%v – %s", err, bytes.TrimSpace(out))})
 	}
 
-	var yt struct {
-		Title      string `json:"title"`
-		Thumbnail  string `json:"thumbnail"`
-		Thumbnails []struct{ URL string }
-		Formats    []struct {
-			Vcodec, Acodec string
-			Height         int
-			Abr            float64
-		}
-		Subtitles map[string][]any `json:"subtitles"`
-	}
-	if err := json.Unmarshal(out, &yt); err != nil {
+	resp, err := parseInfo(out)
+	if err != nil {
 		return e.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	return e.JSON(http.StatusOK, resp)
+}
 
-	vset, aset := map[int]struct{}{}, map[string]struct{}{}
-	for _, f := range yt.Formats {
-		if f.Vcodec != "none" && f.Height > 0 {
-			vset[f.Height] = struct{}{}
-		}
-		if f.Acodec != "none" && f.Vcodec == "none" && f.Abr > 0 {
-			aset[fmt.Sprintf("%.0f", f.Abr)] = struct{}{}
-		}
-	}
-	videoQ := make([]int, 0, len(vset))
-	for h := range vset {
-		videoQ = append(videoQ, h)
-	}
-	sort.Sort(sort.Reverse(sort.IntSlice(videoQ)))
-	audioQ := make([]string, 0, len(aset))
-	for a := range aset {
-		audioQ = append(audioQ, a)
+func startDownloadPB(e *core.RequestEvent) error {
+	url := e.Request.FormValue("url")
+	if url == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "URL requerida"})
 	}
-	sort.Strings(audioQ)
-	langs := make([]string, 0, len(yt.Subtitles))
-	for l := range yt.Subtitles {
-		langs = append(langs, l)
+
+	opts, err := parseJobOptions(e.Request.FormValue)
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	sort.Strings(langs)
 
-	thumb := yt.Thumbnail
-	if len(yt.Thumbnails) > 0 {
-		thumb = yt.Thumbnails[len(yt.Thumbnails)-1].URL
+	id, ticket, reused, err := newJob(url, e.Request.FormValue("cookies"), opts)
+	if errors.Is(err, errArchived) {
+		return e.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
 	}
+	return e.JSON(http.StatusOK, map[string]any{"job": id, "ticket": ticket, "reused": reused})
+}
 
-	resp := infoResp{Title: yt.Title, ThumbURL: thumb, SubLangs: langs}
-	for _, h := range videoQ {
-		resp.VideoQualities = append(resp.VideoQualities, fmt.Sprintf("%d", h))
+func cancelDownloadPB(e *core.RequestEvent) error {
+	err := cancelJob(e.Request.PathValue("id"), e.Request.FormValue("ticket"), e.Auth != nil)
+	switch {
+	case errors.Is(err, errJobNotFound):
+		return e.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case err != nil:
+		return e.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 	}
-	resp.AudioQualities = audioQ
-	return e.JSON(http.StatusOK, resp)
+	return e.JSON(http.StatusOK, map[string]string{"status": "canceled"})
 }
 
-func startDownloadPB(e *core.RequestEvent) error {
-	url := e.Request.FormValue("url")
-	if url == "" {
-		return e.JSON(http.StatusBadRequest, map[string]string{"error": "URL requerida"})
+func createSubscriptionPB(e *core.RequestEvent) error {
+	s, err := createSubscription(e.Request.FormValue)
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	go checkSubscription(s.ID)
+	return e.JSON(http.StatusOK, s)
+}
 
-	id := uuid.New().String()
-	jobsMu.Lock()
-	jobs[id] = &jobInfo{}
-	jobsMu.Unlock()
-
-	go downloadJob(
-		id,
-		url,
-		e.Request.FormValue("cookies"),
-		e.Request.FormValue("type"),
-		e.Request.FormValue("quality"),
-		e.Request.FormValue("sub_lang"),
-	)
-
-	return e.JSON(http.StatusOK, map[string]string{"job": id})
+func checkSubscriptionPB(e *core.RequestEvent) error {
+	id := e.Request.PathValue("id")
+	if err := checkSubscription(id); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	s, ok := getSubscription(id)
+	if !ok {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "suscripción no encontrada"})
+	}
+	return e.JSON(http.StatusOK, s)
 }
 
-func cancelDownloadPB(e *core.RequestEvent) error {
+/* sesión de PB o el ticket de la petición que lanzó el job */
+func sharePB(e *core.RequestEvent) error {
 	id := e.Request.PathValue("id")
-	jobsMu.Lock()
-	defer jobsMu.Unlock()
-	if job, ok := jobs[id]; ok {
-		job.Canceled = true
-		if job.Cmd != nil && job.Cmd.Process != nil {
-			_ = job.Cmd.Process.Kill()
-		}
-		return e.JSON(http.StatusOK, map[string]string{"status": "canceled"})
+	if e.Auth == nil && !holdsTicket(id, e.Request.FormValue("ticket")) {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": errShareAuth.Error()})
+	}
+	status, resp, err := mintShare(id, "/yt", e.Request.FormValue)
+	if err != nil {
+		return e.JSON(status, map[string]string{"error": err.Error()})
 	}
-	return e.JSON(http.StatusNotFound, map[string]string{"error": "job no encontrado"})
+	return e.JSON(status, resp)
 }
 
 // ginContextAdapter adapts core.RequestEvent to mimic minimal gin.Context
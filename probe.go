@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                      ffprobe: duración, pistas y tags                      */
+/* -------------------------------------------------------------------------- */
+
+type probeStream struct {
+	CodecType   string `json:"codec_type"` // video | audio | subtitle | data
+	CodecName   string `json:"codec_name"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Duration    string `json:"duration"`
+	Disposition struct {
+		AttachedPic int `json:"attached_pic"` // portada incrustada, no es video
+	} `json:"disposition"`
+}
+
+type probeResult struct {
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+func probeFile(path string) (probeResult, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-print_format", "json",
+		"-show_format", "-show_streams", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return probeResult{}, fmt.Errorf("ffprobe: %v – %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	var p probeResult
+	if err := json.Unmarshal(out, &p); err != nil {
+		return probeResult{}, fmt.Errorf("ffprobe: %v", err)
+	}
+	return p, nil
+}
+
+/* duración en segundos (la del contenedor o, si falta, la pista más larga) */
+func (p probeResult) duration() float64 {
+	if d, err := strconv.ParseFloat(p.Format.Duration, 64); err == nil {
+		return d
+	}
+	var max float64
+	for _, s := range p.Streams {
+		if d, err := strconv.ParseFloat(s.Duration, 64); err == nil && d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+/* primera pista de video real (sin contar portadas) */
+func (p probeResult) video() (probeStream, bool) {
+	for _, s := range p.Streams {
+		if s.CodecType == "video" && s.Disposition.AttachedPic == 0 {
+			return s, true
+		}
+	}
+	return probeStream{}, false
+}
+
+func (p probeResult) hasAudio() bool {
+	for _, s := range p.Streams {
+		if s.CodecType == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
+/* tag del contenedor sin distinguir mayúsculas (MP4 "title", MKV "TITLE") */
+func (p probeResult) tag(names ...string) string {
+	for _, n := range names {
+		for k, v := range p.Format.Tags {
+			if strings.EqualFold(k, n) && v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
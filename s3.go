@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*          cliente S3 mínimo (SigV4) — AWS, MinIO, R2, Backblaze, …          */
+/* -------------------------------------------------------------------------- */
+
+const (
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	/* un PUT admite hasta 5 GB; por encima de una parte se sube por partes */
+	s3PartSize = 64 << 20
+	s3MaxParts = 10000
+)
+
+/* 404 de S3 (NoSuchKey) */
+var errNoSuchKey = errors.New("404 Not Found")
+
+type s3Storage struct {
+	endpoint  *url.URL // http(s)://host[:puerto]
+	region    string
+	bucket    string
+	prefix    string // prefijo opcional de las claves
+	accessKey string
+	secretKey string
+	pathStyle bool  // MinIO y la mayoría de compatibles usan path-style
+	partSize  int64 // tamaño de parte en subidas multipart (mínimo S3: 5 MiB)
+	client    *http.Client
+}
+
+func newS3Storage(endpoint, region, bucket, prefix, accessKey, secretKey string, pathStyle bool) (*s3Storage, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT inválido: %q", endpoint)
+	}
+	if bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3_BUCKET, S3_ACCESS_KEY y S3_SECRET_KEY son obligatorios")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3Storage{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		prefix:    strings.Trim(prefix, "/"),
+		accessKey: accessKey,
+		secretKey: secretKey,
+		pathStyle: pathStyle,
+		partSize:  s3PartSize,
+		client:    &http.Client{},
+	}, nil
+}
+
+/* URI-encode de SigV4 (RFC 3986, '/' opcional) */
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+/* host y path canónico del objeto */
+func (s *s3Storage) objectURL(key string) (host, path string) {
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	if s.pathStyle {
+		return s.endpoint.Host, "/" + s.bucket + "/" + s3Escape(key, true)
+	}
+	return s.bucket + "." + s.endpoint.Host, "/" + s3Escape(key, true)
+}
+
+/* URL con el path ya codificado tal como se firmó */
+func (s *s3Storage) urlFor(host, escapedPath string) url.URL {
+	u := *s.endpoint
+	u.Host = host
+	u.Path, _ = url.PathUnescape(escapedPath)
+	u.RawPath = escapedPath
+	return u
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (s *s3Storage) signingKey(date string) []byte {
+	k := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	k = hmacSHA256(k, s.region)
+	k = hmacSHA256(k, "s3")
+	return hmacSHA256(k, "aws4_request")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+/* firma SigV4; headers debe contener ya todos los firmados (en minúsculas) */
+func (s *s3Storage) sign(method, path string, query url.Values, headers map[string]string, now time.Time) string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + s.region + "/s3/aws4_request"
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var ch strings.Builder
+	for _, k := range names {
+		ch.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+
+	payload := headers["x-amz-content-sha256"]
+	if payload == "" {
+		payload = unsignedPayload
+	}
+	creq := strings.Join([]string{
+		method, path, canonicalQuery(query), ch.String(), strings.Join(names, ";"), payload,
+	}, "\n")
+	sum := sha256.Sum256([]byte(creq))
+	sts := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	return hex.EncodeToString(hmacSHA256(s.signingKey(date), sts))
+}
+
+/* petición firmada con cabecera Authorization */
+func (s *s3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, extra http.Header) (*http.Response, error) {
+	return s.doQuery(ctx, method, key, url.Values{}, body, size, extra)
+}
+
+/* como do, con parámetros de query firmados (?uploads, ?partNumber=…) */
+func (s *s3Storage) doQuery(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, extra http.Header) (*http.Response, error) {
+	host, path := s.objectURL(key)
+	now := time.Now()
+	amzDate := now.UTC().Format("20060102T150405Z")
+	headers := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": unsignedPayload,
+		"x-amz-date":           amzDate,
+	}
+	sig := s.sign(method, path, query, headers, now)
+
+	u := s.urlFor(host, path)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range extra {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("x-amz-content-sha256", unsignedPayload)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s/%s/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		s.accessKey, amzDate[:8], s.region, sig))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 && res.StatusCode != http.StatusNotModified {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		res.Body.Close()
+		if res.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("s3 %s %s: %w – %s", method, key, errNoSuchKey, strings.TrimSpace(string(b)))
+		}
+		return nil, fmt.Errorf("s3 %s %s: %s – %s", method, key, res.Status, strings.TrimSpace(string(b)))
+	}
+	return res, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	ctype := http.Header{"Content-Type": {mimeFor(localPath)}}
+	if st.Size() > s.partSize {
+		return s.putMultipart(ctx, key, f, st.Size(), ctype)
+	}
+	res, err := s.do(ctx, http.MethodPut, key, f, st.Size(), ctype)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+type s3Part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+/* CreateMultipartUpload → UploadPart… → CompleteMultipartUpload (Abort si falla) */
+func (s *s3Storage) putMultipart(ctx context.Context, key string, f *os.File, size int64, ctype http.Header) (err error) {
+	res, err := s.doQuery(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0, ctype)
+	if err != nil {
+		return err
+	}
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if err != nil || created.UploadID == "" {
+		return fmt.Errorf("s3 multipart %s: respuesta sin UploadId (%v)", key, err)
+	}
+	upload := url.Values{"uploadId": {created.UploadID}}
+	defer func() {
+		if err != nil {
+			if res, aerr := s.doQuery(context.Background(), http.MethodDelete, key, upload, nil, 0, nil); aerr == nil {
+				res.Body.Close()
+			}
+		}
+	}()
+
+	partSize := max(s.partSize, (size+s3MaxParts-1)/s3MaxParts)
+	var parts []s3Part
+	for off, n := int64(0), 1; off < size; off, n = off+partSize, n+1 {
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {created.UploadID}}
+		length := min(partSize, size-off)
+		res, err := s.doQuery(ctx, http.MethodPut, key, q, io.NewSectionReader(f, off, length), length, nil)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		parts = append(parts, s3Part{Number: n, ETag: res.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	res, err = s.doQuery(ctx, http.MethodPost, key, upload, bytes.NewReader(body), int64(len(body)), nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	/* S3 puede responder 200 con un <Error> en el cuerpo */
+	var done struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if derr := xml.NewDecoder(res.Body).Decode(&done); derr == nil && done.XMLName.Local == "Error" {
+		return fmt.Errorf("s3 multipart %s: %s – %s", key, done.Code, done.Message)
+	}
+	return nil
+}
+
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+/* HEAD del objeto: false si no existe, error si no se pudo saber */
+func (s *s3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	res, err := s.do(ctx, http.MethodHead, key, nil, 0, nil)
+	if errors.Is(err, errNoSuchKey) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, res.Body.Close()
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+/* URL prefirmada (query-string SigV4) que fuerza la descarga con name */
+func (s *s3Storage) PresignedURL(key, name string, ttl time.Duration) (string, error) {
+	host, path := s.objectURL(key)
+	now := time.Now()
+	amzDate := now.UTC().Format("20060102T150405Z")
+
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.accessKey+"/"+amzDate[:8]+"/"+s.region+"/s3/aws4_request")
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if name != "" {
+		q.Set("response-content-disposition", contentDisposition(name))
+	}
+	sig := s.sign(http.MethodGet, path, q, map[string]string{"host": host}, now)
+
+	u := s.urlFor(host, path)
+	u.RawQuery = canonicalQuery(q) + "&X-Amz-Signature=" + sig
+	return u.String(), nil
+}
+
+/* proxy de un objeto: reenvía Range / condicionales y copia la respuesta */
+func (s *s3Storage) Serve(w http.ResponseWriter, r *http.Request, key, name string) error {
+	extra := http.Header{}
+	for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := r.Header.Get(h); v != "" {
+			extra.Set(h, v)
+		}
+	}
+	res, err := s.do(r.Context(), http.MethodGet, key, nil, 0, extra)
+	if err != nil {
+		http.Error(w, "almacenamiento no disponible", http.StatusBadGateway)
+		return err
+	}
+	defer res.Body.Close()
+	for _, h := range []string{"Content-Length", "Content-Range", "ETag", "Last-Modified", "Accept-Ranges"} {
+		if v := res.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.Header().Set("Content-Type", mimeFor(name))
+	w.Header().Set("Content-Disposition", contentDisposition(name))
+	w.WriteHeader(res.StatusCode)
+	_, err = io.Copy(w, res.Body)
+	return err
+}
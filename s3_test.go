@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Contra un S3 real (MinIO en local):
+
+	docker run -d --rm -p 9000:9000 -e MINIO_ROOT_USER=minio -e MINIO_ROOT_PASSWORD=minio123 \
+		minio/minio server /data
+	docker run --rm --network host --entrypoint sh minio/mc -c \
+		'mc alias set l http://localhost:9000 minio minio123 && mc mb -p l/yt-test'
+	S3_TEST_ENDPOINT=http://localhost:9000 S3_TEST_BUCKET=yt-test \
+		S3_TEST_ACCESS_KEY=minio S3_TEST_SECRET_KEY=minio123 go test -run S3 .
+
+Sin S3_TEST_ENDPOINT se usa fakeS3, que solo entiende lo que usa s3Storage
+*/
+
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	fail    bool // responde 500 a todo
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		http.Error(w, "<Error><Code>InternalError</Code></Error>", http.StatusInternalServerError)
+		return
+	}
+	key, q := r.URL.Path, r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		f.uploads[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"p%d"`, n))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts := f.uploads[q.Get("uploadId")]
+		nums := make([]int, 0, len(parts))
+		for n := range parts {
+			nums = append(nums, n)
+		}
+		sort.Ints(nums)
+		var all []byte
+		for _, n := range nums {
+			if !bytes.Contains(body, []byte(fmt.Sprintf(`<PartNumber>%d</PartNumber><ETag>&#34;p%d&#34;</ETag>`, n, n))) {
+				fmt.Fprint(w, "<Error><Code>InvalidPart</Code><Message>falta la parte</Message></Error>")
+				return
+			}
+			all = append(all, parts[n]...)
+		}
+		f.objects[key] = all
+		delete(f.uploads, q.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		b, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(b))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "no soportado", http.StatusNotImplemented)
+	}
+}
+
+/* MinIO si está configurado; si no, fakeS3 */
+func testS3(t *testing.T) (*s3Storage, *fakeS3) {
+	t.Helper()
+	if ep := os.Getenv("S3_TEST_ENDPOINT"); ep != "" {
+		s, err := newS3Storage(ep, os.Getenv("S3_TEST_REGION"), os.Getenv("S3_TEST_BUCKET"), "test",
+			os.Getenv("S3_TEST_ACCESS_KEY"), os.Getenv("S3_TEST_SECRET_KEY"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, nil
+	}
+	fake := &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	s, err := newS3Storage(srv.URL, "", "yt", "", "ak", "sk", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, fake
+}
+
+func writeRandom(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	b := make([]byte, size)
+	rand.Read(b)
+	p := filepath.Join(t.TempDir(), "obj.bin")
+	if err := os.WriteFile(p, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p, b
+}
+
+func TestS3PutOpenDelete(t *testing.T) {
+	const minPart = 5 << 20 // mínimo de S3 para las partes (salvo la última)
+	for _, tc := range []struct {
+		name string
+		size int
+	}{
+		{"simple", 1 << 10},
+		{"multipart", 2*minPart + 123},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := testS3(t)
+			s.partSize = minPart
+			path, want := writeRandom(t, tc.size)
+			key := "job/" + tc.name + ".bin"
+			ctx := context.Background()
+			if err := s.Put(ctx, key, path); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Delete(ctx, key)
+
+			rc, err := s.Open(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("contenido distinto: %d bytes, err %v", len(got), err)
+			}
+		})
+	}
+}
+
+func TestS3Exists(t *testing.T) {
+	s, _ := testS3(t)
+	path, _ := writeRandom(t, 64)
+	ctx := context.Background()
+	if err := s.Put(ctx, "job/exists.bin", path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Delete(ctx, "job/exists.bin")
+
+	for key, want := range map[string]bool{"job/exists.bin": true, "job/missing.bin": false} {
+		got, err := s.Exists(ctx, key)
+		if err != nil || got != want {
+			t.Errorf("Exists(%s) = %v, %v; se esperaba %v", key, got, err, want)
+		}
+	}
+}
+
+func TestS3Serve(t *testing.T) {
+	s, _ := testS3(t)
+	path, want := writeRandom(t, 4096)
+	ctx := context.Background()
+	if err := s.Put(ctx, "job/serve.bin", path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Delete(ctx, "job/serve.bin")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Range", "bytes=100-199")
+	w := httptest.NewRecorder()
+	if err := s.Serve(w, r, "job/serve.bin", "serve.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), want[100:200]) {
+		t.Fatalf("Range: código %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+func TestS3ServeError(t *testing.T) {
+	s, fake := testS3(t)
+	if fake == nil {
+		t.Skip("solo con fakeS3")
+	}
+	fake.fail = true
+	w := httptest.NewRecorder()
+	if err := s.Serve(w, httptest.NewRequest(http.MethodGet, "/", nil), "job/x", "x"); err == nil {
+		t.Fatal("se esperaba error")
+	}
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("código %d, se esperaba 502", w.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+/* -------------------------------------------------------------------------- */
+/*        escaneo de downloads/<uuid>/ → biblioteca (archivos antiguos)       */
+/* -------------------------------------------------------------------------- */
+
+type scanReport struct {
+	Dirs    int      `json:"dirs"`
+	Files   int      `json:"files"`   // archivos de audio/video encontrados
+	Added   int      `json:"added"`   // entradas nuevas
+	Skipped int      `json:"skipped"` // ya estaban en la biblioteca
+	Errors  []string `json:"errors,omitempty"`
+}
+
+/* lo tiene el servidor mientras está en marcha, o `yt-dl scan` */
+const dataLockFile = "yt-dl.lock"
+
+var (
+	scanMu      sync.Mutex
+	errScanBusy = errors.New("ya hay un escaneo en curso")
+)
+
+func libraryHas(job, file string) bool {
+	libraryMu.RLock()
+	defer libraryMu.RUnlock()
+	for _, it := range library {
+		if it.Job == job && it.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+/* job todavía en marcha en este proceso: su carpeta aún cambia */
+func jobRunning(id string) bool {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	j, ok := jobs[id]
+	return ok && !j.Ready
+}
+
+/* info.json de yt-dlp para path: el homónimo o el único de su carpeta/job */
+func sidecarMeta(dest, path string) (ytMeta, bool) {
+	var m ytMeta
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	if b, err := os.ReadFile(base + ".info.json"); err == nil && json.Unmarshal(b, &m) == nil {
+		return m, true
+	}
+	for _, dir := range []string{filepath.Dir(path), dest} {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.info.json"))
+		if len(matches) == 1 && readInfoJSON(dir, &m) == nil {
+			return m, true
+		}
+	}
+	/* jobs recientes: metadatos que yt-dlp imprimió durante la descarga */
+	metas := readMeta(dest)
+	for _, mm := range metas {
+		if mm.FilePath != "" && filepath.Clean(mm.FilePath) == filepath.Clean(path) {
+			return mm, true
+		}
+	}
+	/* sin ruta que coincida solo vale si el job era de un único video */
+	for _, mm := range metas {
+		if mm.ID != metas[0].ID {
+			return m, false
+		}
+	}
+	if len(metas) > 0 {
+		return metas[0], true
+	}
+	return m, false
+}
+
+/* metadatos mínimos desde los tags que deja --embed-metadata */
+func probeMeta(p probeResult) ytMeta {
+	m := ytMeta{
+		Title:      p.tag("title"),
+		Uploader:   p.tag("artist", "album_artist"),
+		UploadDate: strings.ReplaceAll(p.tag("date"), "-", ""),
+	}
+	if u := p.tag("purl", "comment"); strings.HasPrefix(u, "http") {
+		m.WebpageURL = u
+	}
+	if len(m.UploadDate) != 8 {
+		m.UploadDate = ""
+	}
+	return m
+}
+
+func scanDir(dest string, rep *scanReport) {
+	job := filepath.Base(dest)
+	files, err := buildManifest(dest)
+	if err != nil {
+		rep.Errors = append(rep.Errors, fmt.Sprintf("%s: %v", job, err))
+		return
+	}
+
+	var items []*libraryItem
+	for _, f := range files {
+		/* por extensión: incluye los capítulos sueltos, no zips ni json */
+		if kind := roleByExt[strings.ToLower(filepath.Ext(f.Name))]; kind != "video" && kind != "audio" {
+			continue
+		}
+		rep.Files++
+		if libraryHas(job, f.Name) {
+			rep.Skipped++
+			continue
+		}
+
+		p, err := probeFile(f.Path)
+		if err != nil {
+			rep.Errors = append(rep.Errors, fmt.Sprintf("%s/%s: %v", job, f.Name, err))
+			continue
+		}
+		media := "audio"
+		if _, ok := p.video(); ok {
+			media = "video"
+		}
+
+		m, ok := sidecarMeta(dest, f.Path)
+		if !ok {
+			m = probeMeta(p)
+		}
+		if f.Role == "chapter" {
+			m.Title = "" // el título del capítulo sale del nombre del archivo
+		}
+		if m.Duration == 0 || f.Role == "chapter" {
+			m.Duration = p.duration()
+		}
+		items = append(items, newLibraryItem(job, media, f, m))
+	}
+
+	if err := addToLibrary(items); err != nil {
+		rep.Errors = append(rep.Errors, fmt.Sprintf("%s: %v", job, err))
+		return
+	}
+	rep.Added += len(items)
+	if err := indexSearch(searchDocs(items, dest)); err != nil {
+		rep.Errors = append(rep.Errors, fmt.Sprintf("%s: búsqueda: %v", job, err))
+	}
+}
+
+/*
+recorre downloadDir e indexa lo que falte. Se puede repetir: lo que ya
+está en la biblioteca (mismo job y archivo) no se vuelve a analizar
+*/
+func scanDownloads() (scanReport, error) {
+	if !scanMu.TryLock() {
+		return scanReport{}, errScanBusy
+	}
+	defer scanMu.Unlock()
+
+	var rep scanReport
+	entries, err := os.ReadDir(downloadDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return rep, nil
+	}
+	if err != nil {
+		return rep, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := uuid.Parse(e.Name()); err != nil || jobRunning(e.Name()) {
+			continue
+		}
+		rep.Dirs++
+		scanDir(filepath.Join(downloadDir, e.Name()), &rep)
+	}
+	return rep, nil
+}
+
+/*
+el servidor reserva dataDir: guarda la biblioteca entera en cada cambio
+y borraría lo que otro proceso añadiera a library.json
+*/
+func lockServer() error {
+	ok, err := lockData(dataLockFile)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s está en uso por otro proceso (¿un `yt-dl scan` o otro servidor?)", dataDir)
+	}
+	return nil
+}
+
+/*
+`yt-dl scan`: escanea, imprime el informe en JSON y termina. Solo con el
+servidor parado; con él en marcha se usa POST /library/scan
+*/
+func runScanCommand() error {
+	ok, err := lockData(dataLockFile)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("el servidor está en marcha: `yt-dl scan` solo funciona con él parado, usa POST /library/scan")
+	}
+	/* lo que el servidor guardó hasta pararse */
+	if err := initLibrary(); err != nil {
+		return err
+	}
+	rep, err := scanDownloads()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+/* -------------------------  /library/scan POST ---------------------------- */
+
+func scanStatus(err error) int {
+	if errors.Is(err, errScanBusy) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func scanGin(c *gin.Context) {
+	rep, err := scanDownloads()
+	if err != nil {
+		c.JSON(scanStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "modernc.org/sqlite"
+)
+
+/* -------------------------------------------------------------------------- */
+/*      búsqueda de texto completo (SQLite FTS5): títulos, descripciones      */
+/*      y texto de los subtítulos de la biblioteca                            */
+/* -------------------------------------------------------------------------- */
+
+const (
+	searchDBFile   = "search.db"
+	maxSearchLimit = 200
+)
+
+var (
+	searchDB       *sql.DB
+	errEmptySearch = errors.New("consulta vacía")
+)
+
+func initSearch() error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	db, err := openSearchDB(filepath.Join(dataDir, searchDBFile))
+	if err != nil {
+		return err
+	}
+	searchDB = db
+	return nil
+}
+
+/* abre (o crea) el índice en path; ":memory:" para uno temporal */
+func openSearchDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // un solo escritor: sin SQLITE_BUSY
+	_, err = db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_docs USING fts5(
+		item UNINDEXED, kind UNINDEXED, lang UNINDEXED, start UNINDEXED, text,
+		tokenize = 'unicode61 remove_diacritics 2')`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("índice de búsqueda: %v", err)
+	}
+	return db, nil
+}
+
+/* fragmento indexable de un elemento de la biblioteca */
+type searchDoc struct {
+	item  *libraryItem // el id definitivo se lee al indexar (addToLibrary lo fija)
+	Kind  string       // title | description | subtitle
+	Lang  string
+	Start float64 // segundos; -1 fuera de los subtítulos
+	Text  string
+}
+
+/* "Titulo.en.vtt" → "en" */
+func subtitleLang(name string) string {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimPrefix(filepath.Ext(base), ".")
+}
+
+/*
+documentos de items leídos de su carpeta dest: título y descripción de
+cada uno y un documento por párrafo de cada subtítulo legible (una sola
+vez por video). Hay que llamarlo antes de que storeOutputs libere el disco
+*/
+func searchDocs(items []*libraryItem, dest string) []searchDoc {
+	metas := readMeta(dest)
+	descs := map[string]string{}
+	for _, m := range metas {
+		if m.Description != "" {
+			descs[m.ID] = m.Description
+		}
+	}
+	subs := subtitleFiles(dest)
+
+	var docs []searchDoc
+	done := map[string]bool{}
+	for _, it := range items {
+		docs = append(docs, searchDoc{item: it, Kind: "title", Start: -1, Text: it.Title})
+		if d := descs[it.VideoID]; d != "" {
+			docs = append(docs, searchDoc{item: it, Kind: "description", Start: -1, Text: d})
+		}
+		if done[it.VideoID] {
+			continue
+		}
+		done[it.VideoID] = true
+		for _, p := range subs {
+			name := filepath.Base(p)
+			if len(metas) > 0 && transcriptMeta(metas, p).ID != it.VideoID {
+				continue
+			}
+			cues, err := readSubtitle(p)
+			if err != nil {
+				continue
+			}
+			for _, s := range buildTranscript(transcriptLines(cues), nil) {
+				for _, para := range s.Paragraphs {
+					docs = append(docs, searchDoc{item: it, Kind: "subtitle", Lang: subtitleLang(name),
+						Start: para.Start.Seconds(), Text: para.Text})
+				}
+			}
+		}
+	}
+	return docs
+}
+
+/* sustituye en el índice los documentos de los items de docs */
+func indexSearch(docs []searchDoc) error {
+	if searchDB == nil || len(docs) == 0 {
+		return nil
+	}
+	tx, err := searchDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := indexDocs(tx, docs, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+sustituye dentro de tx los documentos de cada elemento presente en docs;
+con keepSubs se conservan los de subtítulos que ya estaban indexados
+*/
+func indexDocs(tx *sql.Tx, docs []searchDoc, keepSubs bool) error {
+	del := `DELETE FROM search_docs WHERE item = ?`
+	if keepSubs {
+		del += ` AND kind != 'subtitle'`
+	}
+	seen := map[string]bool{}
+	for _, d := range docs {
+		if !seen[d.item.ID] {
+			seen[d.item.ID] = true
+			if _, err := tx.Exec(del, d.item.ID); err != nil {
+				return err
+			}
+		}
+		if strings.TrimSpace(d.Text) == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO search_docs (item, kind, lang, start, text) VALUES (?, ?, ?, ?, ?)`,
+			d.item.ID, d.Kind, d.Lang, d.Start, d.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* rehace el índice de los jobs que aún tienen su carpeta en downloads/ */
+func reindexSearch() (int, error) {
+	if searchDB == nil {
+		return 0, errors.New("índice de búsqueda no disponible")
+	}
+	libraryMu.RLock()
+	byJob := map[string][]*libraryItem{}
+	var jobsOrder []string
+	for _, it := range library {
+		cp := *it
+		if _, ok := byJob[it.Job]; !ok {
+			jobsOrder = append(jobsOrder, it.Job)
+		}
+		byJob[it.Job] = append(byJob[it.Job], &cp)
+	}
+	libraryMu.RUnlock()
+
+	// en una transacción; los jobs sin carpeta (Storage remoto) conservan sus
+	// documentos y los que ya no tienen los subtítulos (las transcripciones
+	// los borran tras indexarlos), los de subtítulos
+	tx, err := searchDB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	n := 0
+	for _, job := range jobsOrder {
+		dest := filepath.Join(downloadDir, job)
+		if _, err := os.Stat(dest); err != nil {
+			continue
+		}
+		docs := searchDocs(byJob[job], dest)
+		if err := indexDocs(tx, docs, !hasSubtitleDocs(docs)); err != nil {
+			return 0, err
+		}
+		n += len(docs)
+	}
+	return n, tx.Commit()
+}
+
+func hasSubtitleDocs(docs []searchDoc) bool {
+	for _, d := range docs {
+		if d.Kind == "subtitle" {
+			return true
+		}
+	}
+	return false
+}
+
+/* ------------------------------- consultas -------------------------------- */
+
+/*
+texto libre → consulta FTS5: cada palabra entre comillas (sin operadores
+ni sintaxis que pueda fallar), todas obligatorias, la última como prefijo
+*/
+func ftsQuery(q string) string {
+	terms := strings.Fields(q)
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	if len(terms) > 0 {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+type searchHit struct {
+	Item    string   `json:"item"`
+	Title   string   `json:"title"`
+	Kind    string   `json:"kind"` // title | description | subtitle
+	Lang    string   `json:"lang,omitempty"`
+	Start   *float64 `json:"start,omitempty"` // segundos, en los subtítulos
+	Time    string   `json:"time,omitempty"`
+	Snippet string   `json:"snippet"`
+	Link    string   `json:"link"`           // ficha en la biblioteca
+	Play    string   `json:"play,omitempty"` // archivo firmado, en el instante del hit
+}
+
+type searchResp struct {
+	Query string      `json:"query"`
+	Hits  []searchHit `json:"hits"`
+}
+
+func searchText(form func(string) string, prefix string, signed bool) (searchResp, error) {
+	q := strings.TrimSpace(form("q"))
+	match := ftsQuery(q)
+	if match == "" {
+		return searchResp{}, errEmptySearch
+	}
+	if searchDB == nil {
+		return searchResp{}, errors.New("índice de búsqueda no disponible")
+	}
+	limit, offset := 50, 0
+	if v := form("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			return searchResp{}, fmt.Errorf("limit debe estar entre 1 y %d", maxSearchLimit)
+		}
+		limit = n
+	}
+	if v := form("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return searchResp{}, fmt.Errorf("offset inválido: %s", v)
+		}
+		offset = n
+	}
+
+	rows, err := searchDB.Query(`SELECT item, kind, lang, start,
+		snippet(search_docs, 4, '[', ']', '…', 16)
+		FROM search_docs WHERE search_docs MATCH ? ORDER BY rank LIMIT ? OFFSET ?`,
+		match, limit, offset)
+	if err != nil {
+		return searchResp{}, err
+	}
+	defer rows.Close()
+
+	resp := searchResp{Query: q, Hits: []searchHit{}}
+	for rows.Next() {
+		var h searchHit
+		var start float64
+		if err := rows.Scan(&h.Item, &h.Kind, &h.Lang, &start, &h.Snippet); err != nil {
+			return searchResp{}, err
+		}
+		it, ok := getLibraryItem(h.Item)
+		if !ok {
+			continue // borrado de la biblioteca; desaparece al reindexar
+		}
+		v := newLibraryView(it, prefix, signed)
+		h.Title = it.Title
+		h.Link = prefix + "/library/items/" + it.ID
+		h.Play = v.Download
+		if start >= 0 {
+			h.Start = &start
+			h.Time = clockText(time.Duration(start * float64(time.Second)))
+			if h.Play != "" {
+				h.Play += fmt.Sprintf("#t=%.0f", start)
+			}
+		}
+		resp.Hits = append(resp.Hits, h)
+	}
+	return resp, rows.Err()
+}
+
+/* ------------------------  /search GET, /search/reindex POST -------------- */
+
+func searchGin(c *gin.Context) {
+	resp, err := searchText(c.Query, "", authorized(c.Request))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func reindexSearchGin(c *gin.Context) {
+	n, err := reindexSearch()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"documents": n})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"hola", `"hola"*`},
+		{"  hola   mundo ", `"hola" "mundo"*`},
+		{`di"go`, `"di""go"*`},
+		{"NOT OR", `"NOT" "OR"*`},
+		{"title:x -y", `"title:x" "-y"*`},
+	}
+	for _, tt := range tests {
+		if got := ftsQuery(tt.in); got != tt.want {
+			t.Errorf("ftsQuery(%q) = %s, se esperaba %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+/*
+índice en memoria y una biblioteca con un job en downloads/<job>/ (dentro
+de un directorio temporal); lo global se restaura al terminar
+*/
+func testSearch(t *testing.T, items []*libraryItem) string {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	db, err := openSearchDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDB, oldLibrary := searchDB, library
+	searchDB, library = db, items
+	t.Cleanup(func() {
+		db.Close()
+		searchDB, library = oldDB, oldLibrary
+	})
+
+	dest := filepath.Join(downloadDir, items[0].Job)
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"id":"vid1","title":"Receta de paella","description":"Arroz con azafrán y garrofó","filename":"` +
+		filepath.Join(dest, "Receta de paella.mp4") + `"}` + "\n"
+	vtt := "WEBVTT\n\n00:00:01.000 --> 00:00:04.000\nPrimero sofreímos el pollo\n\n" +
+		"00:01:00.000 --> 00:01:03.000\nLuego añadimos el caldo\n"
+	for name, body := range map[string]string{
+		metaFile:                  meta,
+		"Receta de paella.es.vtt": vtt,
+	} {
+		if err := os.WriteFile(filepath.Join(dest, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dest
+}
+
+func searchKinds(t *testing.T, q string) map[string]int {
+	t.Helper()
+	resp, err := searchText(func(k string) string {
+		if k == "q" {
+			return q
+		}
+		return ""
+	}, "", false)
+	if err != nil {
+		t.Fatalf("búsqueda %q: %v", q, err)
+	}
+	kinds := map[string]int{}
+	for _, h := range resp.Hits {
+		if h.Item != "item1" {
+			t.Errorf("búsqueda %q: hit de %q", q, h.Item)
+		}
+		kinds[h.Kind]++
+	}
+	return kinds
+}
+
+func TestSearchIndexRoundTrip(t *testing.T) {
+	item := &libraryItem{ID: "item1", Job: "job1", VideoID: "vid1", Title: "Receta de paella"}
+	dest := testSearch(t, []*libraryItem{item})
+
+	docs := searchDocs([]*libraryItem{item}, dest)
+	if err := indexSearch(docs); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    string
+		kind string
+		want int
+	}{
+		{"paella", "title", 1},
+		{"azafran", "description", 1}, // sin tilde: remove_diacritics
+		{"sofreimos pollo", "subtitle", 1},
+		{"cald", "subtitle", 1}, // prefijo en la última palabra
+		{"paella caldo", "subtitle", 0},
+	}
+	for _, tt := range tests {
+		if got := searchKinds(t, tt.q)[tt.kind]; got != tt.want {
+			t.Errorf("%q: %d hits de %s, se esperaban %d", tt.q, got, tt.kind, tt.want)
+		}
+	}
+
+	/* el inicio de cada párrafo llega al hit */
+	resp, err := searchText(func(k string) string {
+		if k == "q" {
+			return "caldo"
+		}
+		return ""
+	}, "", false)
+	if err != nil || len(resp.Hits) != 1 || resp.Hits[0].Start == nil || *resp.Hits[0].Start != 60 {
+		t.Errorf("caldo: %+v (%v), se esperaba un hit en el segundo 60", resp.Hits, err)
+	}
+
+	/* volver a indexar sustituye: no duplica */
+	if err := indexSearch(docs); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchKinds(t, "paella")["title"]; got != 1 {
+		t.Errorf("tras indexar dos veces: %d hits de título, se esperaba 1", got)
+	}
+}
+
+func TestReindexKeepsSubtitles(t *testing.T) {
+	item := &libraryItem{ID: "item1", Job: "job1", VideoID: "vid1", Title: "Receta de paella"}
+	dest := testSearch(t, []*libraryItem{item})
+	if err := indexSearch(searchDocs([]*libraryItem{item}, dest)); err != nil {
+		t.Fatal(err)
+	}
+
+	/* como tras una transcripción: el subtítulo ya no está en la carpeta */
+	if err := os.Remove(filepath.Join(dest, "Receta de paella.es.vtt")); err != nil {
+		t.Fatal(err)
+	}
+	item.Title = "Paella valenciana"
+	if _, err := reindexSearch(); err != nil {
+		t.Fatal(err)
+	}
+	kinds := searchKinds(t, "pollo")
+	if kinds["subtitle"] != 1 {
+		t.Errorf("reindexar sin el subtítulo borró sus hits: %v", kinds)
+	}
+	if got := searchKinds(t, "valenciana")["title"]; got != 1 {
+		t.Errorf("reindexar no actualizó el título: %d hits", got)
+	}
+	if got := searchKinds(t, "receta")["title"]; got != 0 {
+		t.Errorf("el título anterior sigue indexado: %d hits", got)
+	}
+}
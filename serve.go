@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*          entrega de archivos: Range, ETag, condicionales y UTF-8           */
+/* -------------------------------------------------------------------------- */
+
+/* transliteración mínima para el filename ASCII de respaldo */
+var asciiFallback = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U", "Ñ", "N",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u", "ç", "c", "Ç", "C",
+	"â", "a", "ê", "e", "î", "i", "ô", "o", "û", "u", "ã", "a", "õ", "o",
+	"¿", "", "¡", "",
+)
+
+/* RFC 5987 attr-char: lo que no está aquí se codifica como %XX */
+func isAttrChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+/*
+Content-Disposition según RFC 6266: filename ASCII de respaldo entre
+comillas + filename* en UTF-8 para clientes modernos
+*/
+func contentDisposition(name string) string {
+	var fb strings.Builder
+	for _, r := range asciiFallback.Replace(name) {
+		switch {
+		case r == '"' || r == '\\':
+			fb.WriteByte('_')
+		case r < 0x20 || r > 0x7e:
+			fb.WriteByte('_')
+		default:
+			fb.WriteRune(r)
+		}
+	}
+
+	var enc strings.Builder
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; isAttrChar(c) {
+			enc.WriteByte(c)
+		} else {
+			fmt.Fprintf(&enc, "%%%02X", c)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fb.String(), enc.String())
+}
+
+/* ETag fuerte: los archivos de un job no cambian una vez terminado */
+func fileETag(st os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, st.Size(), st.ModTime().UnixNano())
+}
+
+/*
+sirve path como adjunto. http.ServeContent resuelve Range, If-Range,
+If-None-Match, If-Modified-Since y Last-Modified a partir del ETag y la
+fecha de modificación
+*/
+func serveDownload(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "archivo no disponible", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		http.Error(w, "archivo no disponible", http.StatusNotFound)
+		return
+	}
+
+	name := filepath.Base(path)
+	h := w.Header()
+	h.Set("Content-Type", mimeFor(name))
+	h.Set("Content-Disposition", contentDisposition(name))
+	h.Set("ETag", fileETag(st))
+	h.Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, name, st.ModTime(), f)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* -------------------------------------------------------------------------- */
+/*          enlaces de descarga firmados (HMAC) con caducidad y límite        */
+/* -------------------------------------------------------------------------- */
+
+/*
+Un enlace autoriza todos los archivos de un job:
+
+	/download/<id>[/<archivo>]?lid=<enlace>&exp=<unix>&max=<n>&sig=<hmac>
+
+max=0 significa sin límite de descargas. Los usos se cuentan por enlace
+(lid) en data/shares.json al servir el archivo; las peticiones Range
+que continúan una descarga no cuentan, las de paquetes siempre. Solo
+POST /share/:id emite enlaces: exige autenticación (token de API en gin,
+sesión en PocketBase) o el ticket que recibió quien lanzó el job, que
+así puede bajar sus archivos
+*/
+
+const (
+	sharesFile   = "shares.json"
+	shareKeyFile = "share.key"
+	maxShareTTL  = 30 * 24 * time.Hour
+)
+
+type shareUse struct {
+	Job     string    `json:"job"`
+	Used    int       `json:"used"`
+	Expires time.Time `json:"expires"`
+}
+
+var (
+	shareSecret []byte
+	shareTTL    = 24 * time.Hour // enlace que recibe quien lanza el job
+	shares      = map[string]*shareUse{}
+	sharesMu    sync.Mutex
+)
+
+/*
+clave HMAC: SHARE_SECRET o, si no existe, una aleatoria guardada en
+data/share.key para que los enlaces sobrevivan a un reinicio.
+SHARE_TTL cambia la validez de los enlaces automáticos
+*/
+func initShares() error {
+	if v := os.Getenv("SHARE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("SHARE_TTL inválido: %q", v)
+		}
+		shareTTL = d
+	}
+	if err := loadJSON(sharesFile, &shares); err != nil {
+		return err
+	}
+
+	if s := os.Getenv("SHARE_SECRET"); s != "" {
+		shareSecret = []byte(s)
+		return nil
+	}
+	path := filepath.Join(dataDir, shareKeyFile)
+	if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
+		shareSecret = b
+		return nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return err
+	}
+	shareSecret = key
+	return nil
+}
+
+func shareSig(id, lid, exp, max string) string {
+	return hex.EncodeToString(hmacSHA256(shareSecret, id+"\n"+lid+"\n"+exp+"\n"+max))
+}
+
+/* query string firmada para el job id */
+func shareLink(id string, ttl time.Duration, max int) (string, time.Time, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	lid := hex.EncodeToString(b)
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	m := strconv.Itoa(max)
+
+	q := url.Values{}
+	q.Set("lid", lid)
+	q.Set("exp", exp)
+	q.Set("max", m)
+	q.Set("sig", shareSig(id, lid, exp, m))
+	return q.Encode(), expires, nil
+}
+
+/*
+¿la respuesta a r incluye el byte 0 de un archivo de size bytes? Solo se
+libra un único rango válido que empieza más adelante, como al continuar
+una descarga. Con varios rangos, If-Range (el rango se puede ignorar) o
+un Range que no se entiende se puede enviar el archivo entero, así que
+cuentan; size < 0 (la respuesta no respeta Range) cuenta siempre
+*/
+func servesFirstByte(r *http.Request, size int64) bool {
+	rg := r.Header.Get("Range")
+	if rg == "" || size < 0 || r.Header.Get("If-Range") != "" {
+		return true
+	}
+	spec, ok := strings.CutPrefix(rg, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return true
+	}
+	start, end, ok := strings.Cut(spec, "-")
+	if !ok {
+		return true
+	}
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if start == "" {
+		/* sufijo: los últimos n bytes, el archivo entero si n >= size */
+		n, err := strconv.ParseInt(end, 10, 64)
+		return err != nil || n >= size
+	}
+	from, err := strconv.ParseInt(start, 10, 64)
+	if err != nil || from < 0 {
+		return true
+	}
+	if end != "" {
+		if to, err := strconv.ParseInt(end, 10, 64); err != nil || to < from {
+			return true
+		}
+	}
+	return from == 0
+}
+
+/*
+valida la firma y la caducidad del enlace. status != 0 indica que no se
+debe servir nada; el uso se registra aparte con useShare
+*/
+func checkShare(id string, r *http.Request) (int, error) {
+	q := r.URL.Query()
+	lid, exp, max, sig := q.Get("lid"), q.Get("exp"), q.Get("max"), q.Get("sig")
+	if lid == "" || exp == "" || sig == "" {
+		return http.StatusForbidden, errors.New("enlace sin firma")
+	}
+	if !hmac.Equal([]byte(sig), []byte(shareSig(id, lid, exp, max))) {
+		return http.StatusForbidden, errors.New("firma inválida")
+	}
+	ts, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return http.StatusForbidden, errors.New("firma inválida")
+	}
+	if time.Now().After(time.Unix(ts, 0)) {
+		return http.StatusGone, errors.New("enlace caducado")
+	}
+	return 0, nil
+}
+
+/*
+cuenta una descarga con el enlace de r, ya validado con checkShare; se
+llama con el archivo encontrado, justo antes de servirlo. size es el del
+archivo o -1 si la respuesta no respeta Range (paquetes, redirecciones)
+*/
+func useShare(id string, r *http.Request, size int64) (int, error) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("max"))
+	if limit <= 0 || r.Method == http.MethodHead && size >= 0 || !servesFirstByte(r, size) {
+		return 0, nil
+	}
+	ts, _ := strconv.ParseInt(q.Get("exp"), 10, 64)
+	lid := q.Get("lid")
+
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+	u, ok := shares[lid]
+	if !ok {
+		u = &shareUse{Job: id, Expires: time.Unix(ts, 0)}
+		shares[lid] = u
+	}
+	if u.Used >= limit {
+		return http.StatusGone, errors.New("enlace agotado")
+	}
+	u.Used++
+
+	/* los enlaces caducados ya no necesitan contador */
+	now := time.Now()
+	for k, s := range shares {
+		if now.After(s.Expires) {
+			delete(shares, k)
+		}
+	}
+	if err := saveJSON(sharesFile, shares); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 0, nil
+}
+
+/* sirve f con el enlace de r: registra el uso y lo envía */
+func serveShared(w http.ResponseWriter, r *http.Request, id string, f outputFile) (int, error) {
+	if status, err := useShare(id, r, shareSize(f)); status != 0 {
+		return status, err
+	}
+	serveOutput(w, r, f)
+	return 0, nil
+}
+
+/* ttl ("2h", "30m"; vacío = SHARE_TTL) y max de un formulario */
+func parseShareForm(form func(string) string) (time.Duration, int, error) {
+	ttl := shareTTL
+	if v := form("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxShareTTL {
+			return 0, 0, fmt.Errorf("ttl inválido: %s (máx. %s)", v, maxShareTTL)
+		}
+		ttl = d
+	}
+	max := 0
+	if v := form("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("max inválido: %s", v)
+		}
+		max = n
+	}
+	return ttl, max, nil
+}
+
+type shareResp struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+	Max     int       `json:"max"`
+}
+
+/*
+enlace nuevo para un job terminado o un elemento de la biblioteca;
+prefix es la raíz de las rutas
+*/
+func mintShare(id, prefix string, form func(string) string) (int, shareResp, error) {
+	path := "/download/" + id
+	ready, failed, ok := jobStatus(id)
+	switch {
+	case !ok:
+		if _, inLibrary := getLibraryItem(id); !inLibrary {
+			return http.StatusNotFound, shareResp{}, errors.New("job no encontrado")
+		}
+		path = "/library/items/" + id + "/file"
+	case failed || !ready:
+		return http.StatusConflict, shareResp{}, errors.New("el job no ha terminado correctamente")
+	}
+	ttl, max, err := parseShareForm(form)
+	if err != nil {
+		return http.StatusBadRequest, shareResp{}, err
+	}
+	q, expires, err := shareLink(id, ttl, max)
+	if err != nil {
+		return http.StatusInternalServerError, shareResp{}, err
+	}
+	return http.StatusOK, shareResp{URL: prefix + path + "?" + q, Expires: expires, Max: max}, nil
+}
+
+/* ---------------------------  /share/:id POST ----------------------------- */
+
+var errShareAuth = errors.New("se requiere el token de API o el ticket del job")
+
+/* token de API o el ticket de la petición que lanzó el job */
+func shareGin(c *gin.Context) {
+	id := c.Param("id")
+	if !authorized(c.Request) && !holdsTicket(id, c.PostForm("ticket")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errShareAuth.Error()})
+		return
+	}
+	status, resp, err := mintShare(id, "", c.PostForm)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, resp)
+}
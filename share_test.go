@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* clave fija y contadores vacíos; los usos se guardan en un dataDir temporal */
+func testShares(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	oldSecret, oldShares := shareSecret, shares
+	shareSecret, shares = []byte("clave de prueba"), map[string]*shareUse{}
+	t.Cleanup(func() { shareSecret, shares = oldSecret, oldShares })
+}
+
+func shareRequest(method, id, query, rng string) *http.Request {
+	r := httptest.NewRequest(method, "/download/"+id+"?"+query, nil)
+	if rng != "" {
+		r.Header.Set("Range", rng)
+	}
+	return r
+}
+
+func TestCheckShare(t *testing.T) {
+	testShares(t)
+	link := func(ttl time.Duration, max int) string {
+		q, _, err := shareLink("job", ttl, max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	tamper := func(q, key, val string) string {
+		v, _ := url.ParseQuery(q)
+		v.Set(key, val)
+		return v.Encode()
+	}
+	valid := link(time.Hour, 0)
+
+	tests := []struct {
+		name  string
+		id    string
+		query string
+		want  int
+	}{
+		{"válido", "job", valid, 0},
+		{"sin firma", "job", "", http.StatusForbidden},
+		{"otro job", "otro", valid, http.StatusForbidden},
+		{"caducidad alterada", "job", tamper(valid, "exp", "9999999999"), http.StatusForbidden},
+		{"límite alterado", "job", tamper(valid, "max", "5"), http.StatusForbidden},
+		{"firma alterada", "job", tamper(valid, "sig", "00"), http.StatusForbidden},
+		{"caducado", "job", link(-time.Minute, 0), http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := checkShare(tt.id, shareRequest(http.MethodGet, tt.id, tt.query, "")); got != tt.want {
+				t.Errorf("checkShare = %d (%v), se esperaba %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckShareLimit(t *testing.T) {
+	testShares(t)
+	q, _, err := shareLink("job", time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// solo cuentan las respuestas que incluyen el byte 0 de un archivo de
+	// 1000 bytes; size -1 (paquetes) cuenta aunque pida un rango
+	steps := []struct {
+		name   string
+		method string
+		rng    string
+		size   int64
+		want   int
+	}{
+		{"primera", http.MethodGet, "", 1000, 0},
+		{"HEAD no cuenta", http.MethodHead, "", 1000, 0},
+		{"continuación no cuenta", http.MethodGet, "bytes=500-", 1000, 0},
+		{"sufijo parcial no cuenta", http.MethodGet, "bytes=-10", 1000, 0},
+		{"segunda con sufijo que lo cubre", http.MethodGet, "bytes=-999999999999", 1000, 0},
+		{"agotado", http.MethodGet, "", 1000, http.StatusGone},
+		{"varios rangos desde 0", http.MethodGet, "bytes=0-0,1-", 1000, http.StatusGone},
+		{"paquete con rango", http.MethodGet, "bytes=1-", -1, http.StatusGone},
+		{"continuación de un agotado", http.MethodGet, "bytes=500-", 1000, 0},
+	}
+	for _, s := range steps {
+		r := shareRequest(s.method, "job", q, s.rng)
+		if status, err := checkShare("job", r); status != 0 {
+			t.Fatalf("%s: checkShare = %d (%v)", s.name, status, err)
+		}
+		if got, err := useShare("job", r, s.size); got != s.want {
+			t.Fatalf("%s: useShare = %d (%v), se esperaba %d", s.name, got, err, s.want)
+		}
+	}
+}
+
+func TestServesFirstByte(t *testing.T) {
+	tests := []struct {
+		rng     string
+		ifRange bool
+		size    int64
+		want    bool
+	}{
+		{"", false, 1000, true},
+		{"bytes=0-", false, 1000, true},
+		{"bytes=0-99", false, 1000, true},
+		{"bytes=500-", false, 1000, false},
+		{"bytes=500-599", false, 1000, false},
+		{"bytes=-10", false, 1000, false},
+		{"bytes=-1000", false, 1000, true},
+		{"bytes=-999999999999", false, 1000, true},
+		{"bytes=0-0,1-", false, 1000, true},
+		{"bytes=500-,600-", false, 1000, true},
+		{"bytes=500-", true, 1000, true}, // If-Range puede ignorar el rango
+		{"bytes=500-", false, -1, true},
+		{"bytes=abc", false, 1000, true},
+		{"bytes=600-500", false, 1000, true},
+		{"items=500-", false, 1000, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/download/job", nil)
+		if tt.rng != "" {
+			r.Header.Set("Range", tt.rng)
+		}
+		if tt.ifRange {
+			r.Header.Set("If-Range", `"etag"`)
+		}
+		if got := servesFirstByte(r, tt.size); got != tt.want {
+			t.Errorf("servesFirstByte(%q, If-Range=%v, %d) = %v, se esperaba %v", tt.rng, tt.ifRange, tt.size, got, tt.want)
+		}
+	}
+}
+
+/* un archivo que no existe no gasta el enlace */
+func TestShareNotUsedOnMissingFile(t *testing.T) {
+	testShares(t)
+	testJobs(t, map[string]*jobInfo{"test-pending": {}})
+	q, _, err := shareLink("test-pending", time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/download/test-pending", "/download/test-pending/nada.mp4"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, path+"?"+q, nil)
+		c.Params = gin.Params{{Key: "id", Value: "test-pending"}, {Key: "file", Value: "/nada.mp4"}}
+		if path == "/download/test-pending" {
+			serveFileGin(c)
+		} else {
+			serveJobFileGin(c)
+		}
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: %d, se esperaba 404", path, w.Code)
+		}
+	}
+	if len(shares) != 0 {
+		t.Errorf("el enlace se gastó sin servir nada: %+v", shares)
+	}
+}
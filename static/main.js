@@ -7,6 +7,31 @@ document.addEventListener("DOMContentLoaded", () => {
   const qualityRow = document.getElementById("qualityRow");
   const langSel = document.getElementById("langSelect");
   const langRow = document.getElementById("langRow");
+  const subFormatSel = document.getElementById("subFormatSelect");
+  const subShiftInput = document.getElementById("subShiftInput");
+  const videoRow = document.getElementById("videoRow");
+  const containerSel = document.getElementById("containerSelect");
+  const codecSel = document.getElementById("codecSelect");
+  const fpsSel = document.getElementById("fpsSelect");
+  const hdrSel = document.getElementById("hdrSelect");
+  const audioRow = document.getElementById("audioRow");
+  const audioFormatSel = document.getElementById("audioFormatSelect");
+  const audioBitrateSel = document.getElementById("audioBitrateSelect");
+  const chaptersRow = document.getElementById("chaptersRow");
+  const splitChk = document.getElementById("splitChapters");
+  const chapterCount = document.getElementById("chapterCount");
+  const rangesRow = document.getElementById("rangesRow");
+  const embedRow = document.getElementById("embedRow");
+  const embedSubsChk = document.getElementById("embedSubs");
+  const rangesInput = document.getElementById("rangesInput");
+  const accurateChk = document.getElementById("accurateCut");
+  const transcriptRow = document.getElementById("transcriptRow");
+  const transcriptTsChk = document.getElementById("transcriptTimestamps");
+  const transcriptChChk = document.getElementById("transcriptChapters");
+  const commentsRow = document.getElementById("commentsRow");
+  const maxCommentsInput = document.getElementById("maxComments");
+  const commentSortSel = document.getElementById("commentSortSelect");
+  const verifyRetriesSel = document.getElementById("verifyRetriesSelect");
   const actionBtn = document.getElementById("actionBtn");
   const progressBox = document.getElementById("progressContainer");
   const stageSpan = document.getElementById("stageText");
@@ -18,6 +43,9 @@ document.addEventListener("DOMContentLoaded", () => {
   const cookiesTA = document.getElementById("cookiesArea");
   const closeSet = document.getElementById("closeSettings");
   const clearBtn = document.getElementById("clearCookies");
+  const profileInput = document.getElementById("profileInput");
+  const tokenInput = document.getElementById("tokenInput");
+  const forceChk = document.getElementById("forceChk");
 
   /* ------------- toast ---------------- */
   function toast(msg, ok = true) {
@@ -32,37 +60,94 @@ document.addEventListener("DOMContentLoaded", () => {
   cookiesTA.value = localStorage.getItem("ytCookies") || "";
   cookiesTA.oninput = () => localStorage.setItem("ytCookies", cookiesTA.value.trim());
   clearBtn.onclick = () => { cookiesTA.value = ""; cookiesTA.oninput(); toast("Cookies eliminadas") };
+  tokenInput.value = localStorage.getItem("ytToken") || "";
+  tokenInput.oninput = () => localStorage.setItem("ytToken", tokenInput.value.trim());
+  profileInput.value = localStorage.getItem("ytProfile") || "";
+  profileInput.oninput = () => localStorage.setItem("ytProfile", profileInput.value.trim());
 
   settingsBtn.onclick = () => dialog.showModal();
   closeSet.onclick = () => dialog.close();
 
   /* ------------- estado runtime ---------- */
-  let lastInfo = null, currentJob = null, es = null;
+  let lastInfo = null, currentJob = null, currentTicket = null, es = null;
   const getCookies = () => cookiesTA.value.trim();
 
   /* ------------- UI helpers -------------- */
+  /* resoluciones alcanzables con el contenedor y códec elegidos */
+  function reachableHeights() {
+    const v = lastInfo.video;
+    if (!v) return lastInfo.video_qualities;
+    let list = (v.containers && v.containers[containerSel.value]) || [];
+    if (codecSel.value) {
+      const byCodec = (v.codecs && v.codecs[codecSel.value]) || [];
+      list = list.filter(h => byCodec.includes(h));
+    }
+    return list;
+  }
+
   function populateQualities() {
     qualitySel.innerHTML = '<option value="">Auto</option>';
     if (!lastInfo) return;
-    const list = typeSel.value === "audio" ? lastInfo.audio_qualities : lastInfo.video_qualities;
+    const list = typeSel.value === "audio" ? lastInfo.audio_qualities : reachableHeights();
     list.forEach(val => {
       const label = typeSel.value === "audio" ? `${val} kbps` : `${val}p`;
       qualitySel.insertAdjacentHTML("beforeend", `<option value="${val}">${label}</option>`);
     });
   }
 
+  /* formatos de audio: indica cuáles evitan re-codificar */
+  const AUDIO_LABELS = { original: "Original", m4a: "M4A (AAC)", opus: "Opus", mp3: "MP3", flac: "FLAC", wav: "WAV" };
+  function populateAudioFormats() {
+    const prev = audioFormatSel.value || "mp3";
+    audioFormatSel.innerHTML = "";
+    (lastInfo.audio_formats || []).forEach(({ format, transcode }) => {
+      const hint = transcode ? "re-codifica" : "sin re-codificar";
+      audioFormatSel.insertAdjacentHTML("beforeend",
+        `<option value="${format}">${AUDIO_LABELS[format] || format} (${hint})</option>`);
+    });
+    audioFormatSel.value = prev;
+  }
+
+  /* subtítulos manuales y automáticos en grupos separados */
+  function populateLangs() {
+    langSel.innerHTML = "";
+    const group = (label, list, auto) => {
+      if (!list || !list.length) return;
+      const opts = list.map(l => `<option value="${l}" data-auto="${auto}">${l}</option>`).join("");
+      langSel.insertAdjacentHTML("beforeend", `<optgroup label="${label}">${opts}</optgroup>`);
+    };
+    group("Manuales", lastInfo.sub_langs, false);
+    group("Automáticos", lastInfo.auto_sub_langs, true);
+  }
+
   function toggleRows() {
     const t = typeSel.value;
-    qualityRow.classList.toggle("hidden", t === "subs" || t === "thumb");
-    langRow.classList.toggle("hidden", t !== "subs");
+    qualityRow.classList.toggle("hidden", ["subs", "thumb", "comments", "transcript"].includes(t));
+    transcriptRow.classList.toggle("hidden", t !== "transcript");
+    subFormatSel.parentElement.classList.toggle("hidden", t === "transcript" || t === "archive");
+    subShiftInput.parentElement.classList.toggle("hidden", t !== "subs");
+    commentsRow.classList.toggle("hidden", t !== "comments");
+    audioRow.classList.toggle("hidden", t !== "audio");
+    videoRow.classList.toggle("hidden", t !== "video" && t !== "archive");
+    const hasChapters = !!(lastInfo && lastInfo.chapters && lastInfo.chapters.length);
+    chaptersRow.classList.toggle("hidden", !hasChapters || (t !== "video" && t !== "audio"));
+    chapterCount.textContent = hasChapters ? lastInfo.chapters.length : 0;
+    rangesRow.classList.toggle("hidden", t !== "video" && t !== "audio");
+    embedRow.classList.toggle("hidden", t !== "video" && t !== "audio");
+    embedSubsChk.parentElement.classList.toggle("hidden", t !== "video");
+    langRow.classList.toggle("hidden", !["subs", "archive", "transcript"].includes(t) && !(t === "video" && embedSubsChk.checked));
     thumbImg.classList.toggle("hidden", t !== "thumb" || !lastInfo);
     if (t === "thumb" && lastInfo) thumbImg.src = lastInfo.thumb_url;
     populateQualities();
   }
   typeSel.onchange = toggleRows;
+  embedSubsChk.onchange = toggleRows;
+  containerSel.onchange = populateQualities;
+  codecSel.onchange = populateQualities;
 
   function resetUI(msg) {
     currentJob = null;
+    currentTicket = null;
     actionBtn.textContent = "Descargar";
     actionBtn.dataset.mode = "start";
     progressBox.classList.add("hidden");
@@ -88,13 +173,44 @@ document.addEventListener("DOMContentLoaded", () => {
     }
     lastInfo = await r.json();
     populateQualities();
-    langSel.innerHTML = "";
-    lastInfo.sub_langs.forEach(l => langSel.insertAdjacentHTML("beforeend", `<option value="${l}">${l}</option>`));
+    populateLangs();
+    populateAudioFormats();
     thumbImg.src = lastInfo.thumb_url;
     toggleRows();
     toast("Datos obtenidos satisfactoriamente");
   };
 
+  /* ------------- enlace firmado ---------- */
+  /* /share acepta el ticket del job (o el token); la URL se resuelve junto a esta página */
+  async function mintLink(jobId, ticket) {
+    const token = tokenInput.value.trim();
+    const fd = new FormData();
+    fd.append("ticket", ticket || "");
+    const r = await fetch(`./share/${jobId}`, {
+      method: "POST",
+      headers: token ? { Authorization: `Bearer ${token}` } : {},
+      body: fd,
+    });
+    const data = await r.json().catch(() => ({}));
+    if (!r.ok) throw new Error(data.error || `HTTP ${r.status}`);
+    return new URL("." + data.url.replace(/^\/yt/, ""), location.href).href;
+  }
+
+  /* ------------- manifest del job -------- */
+  async function showFiles(jobId, link) {
+    const r = await fetch(`./jobs/${jobId}`);
+    if (!r.ok) return;
+    const { files } = await r.json();
+    if (!files || files.length < 2) return;
+    /* la firma del enlace vale para todos los archivos del job */
+    const [baseUrl, sig = ""] = link.split("?");
+    const mb = n => (n / 1024 / 1024).toFixed(1) + " MB";
+    const items = files.map(f =>
+      `<li><a href="${baseUrl}/${encodeURI(f.name)}?${sig}" target="_blank" rel="noopener">${f.name}</a> <small>${f.role} · ${mb(f.size)}</small></li>`);
+    resultP.insertAdjacentHTML("beforeend", `<ul>${items.join("")}</ul>
+      <p>Todo junto: <a href="${baseUrl}?${sig}&archive=zip">ZIP</a> · <a href="${baseUrl}?${sig}&archive=tar.gz">tar.gz</a></p>`);
+  }
+
   /* ------------- Descargar / Cancelar ---- */
   async function startDownload() {
     actionBtn.textContent = "Cancelar";
@@ -108,35 +224,88 @@ document.addEventListener("DOMContentLoaded", () => {
     fd.append("url", urlInput.value.trim());
     fd.append("type", typeSel.value);
     fd.append("quality", qualitySel.value);
-    fd.append("sub_lang", langSel.value);
+    const langs = [...langSel.selectedOptions];
+    fd.append("sub_langs", langs.map(o => o.value).join(","));
+    fd.append("auto_subs", langs.some(o => o.dataset.auto === "true") ? "1" : "");
+    fd.append("sub_format", subFormatSel.value);
+    if (typeSel.value === "subs" && parseFloat(subShiftInput.value)) fd.append("sub_shift", subShiftInput.value);
+    if (typeSel.value === "video" || typeSel.value === "archive") {
+      fd.append("container", containerSel.value);
+      fd.append("video_codec", codecSel.value);
+      fd.append("max_fps", fpsSel.value);
+      fd.append("hdr", hdrSel.value);
+    }
+    if (typeSel.value === "audio") {
+      fd.append("audio_format", audioFormatSel.value);
+      const br = audioBitrateSel.value;
+      if (br.startsWith("vbr:")) fd.append("audio_vbr", br.slice(4));
+      else if (br) fd.append("audio_bitrate", br);
+    }
+    if (typeSel.value === "transcript") {
+      if (transcriptTsChk.checked) fd.append("timestamps", "1");
+      if (transcriptChChk.checked) fd.append("chapter_headings", "1");
+    }
+    if (typeSel.value === "comments") {
+      if (maxCommentsInput.value) fd.append("max_comments", maxCommentsInput.value);
+      fd.append("comment_sort", commentSortSel.value);
+    }
+    if (!chaptersRow.classList.contains("hidden") && splitChk.checked) fd.append("split_chapters", "1");
+    if (!embedRow.classList.contains("hidden")) {
+      ["Metadata", "Thumbnail", "Chapters", "Subs"].forEach(k => {
+        const el = document.getElementById("embed" + k);
+        if (el.checked && !el.parentElement.classList.contains("hidden")) fd.append("embed_" + k.toLowerCase(), "1");
+      });
+    }
+    if (!rangesRow.classList.contains("hidden") && rangesInput.value.trim()) {
+      fd.append("ranges", rangesInput.value.trim());
+      if (accurateChk.checked) fd.append("accurate_cut", "1");
+    }
+    if (!rangesRow.classList.contains("hidden")) fd.append("verify_retries", verifyRetriesSel.value);
+    if (profileInput.value.trim()) fd.append("profile", profileInput.value.trim());
+    if (forceChk.checked) fd.append("force", "1");
     fd.append("cookies", getCookies());
 
     const res = await fetch("./download", { method: "POST", body: fd });
-    const { job, error } = await res.json();
+    const { job, ticket, error, reused } = await res.json();
     if (error) { resetUI(error); toast("Error: " + error, false); return }
+    if (reused) toast("Mismo video y opciones que otra descarga: se reutiliza");
     currentJob = job;
+    currentTicket = ticket;
     es = new EventSource(`./progress/${job}`);
 
     es.onmessage = ev => { bar.style.width = parseInt(ev.data, 10) + "%"; };
 
     es.addEventListener("stage", ev => { stageSpan.textContent = ev.data; });
 
+    let embedded = "";
+    es.addEventListener("embedded", ev => { embedded = ev.data; });
+
+    let issues = "";
+    es.addEventListener("issues", ev => { issues = ev.data; });
+
     // es.addEventListener("ready", ev => {
     //   es.close();
     //   stageSpan.textContent = "Completado ✔";
     //   resetUI(`<a href="${ev.data}" target="_blank">Descargar archivo</a>`);
     //   toast("Descarga completa ✔");
     // });
-    es.addEventListener("ready", ev => {
+    es.addEventListener("ready", async () => {
       es.close();
       stageSpan.textContent = "Completado ✔";
 
-      /* si el backend envía "/download/uuid", anteponemos el mismo origen https://dominio */
-      const url = ev.data.startsWith("http")
-        ? ev.data
-        : `${location.origin}/yt${ev.data}`;
-
-      resetUI(`<a href="${url}" target="_blank" rel="noopener">Descargar archivo</a>`);
+      const note = embedded ? ` <small>(incrustado: ${embedded.split(",").join(", ")})</small>` : "";
+      const warn = issues ? `<br><small>⚠ Verificación: ${issues}</small>` : "";
+      const jobId = currentJob;
+      let url;
+      try {
+        url = await mintLink(jobId, currentTicket);
+      } catch (err) {
+        resetUI(`Descarga lista, pero no se pudo obtener el enlace: ${err.message}${note}${warn}`);
+        toast("No se pudo obtener el enlace", false);
+        return;
+      }
+      resetUI(`<a href="${url}" target="_blank" rel="noopener">Descargar archivo</a>${note}${warn}`);
+      showFiles(jobId, url);
       toast("Descarga completa ✔");
     });
 
@@ -148,12 +317,36 @@ document.addEventListener("DOMContentLoaded", () => {
 
   async function cancelDownload() {
     if (!currentJob) return;
-    await fetch(`./cancel/${currentJob}`, { method: "POST" });
+    // el ticket solo suelta esta petición: si el job es compartido sigue para los demás
+    const fd = new FormData();
+    fd.append("ticket", currentTicket || "");
+    await fetch(`./cancel/${currentJob}`, { method: "POST", body: fd });
     if (es) es.close();
     resetUI("Descarga cancelada");
     toast("Descarga cancelada", false);
   }
 
+  /* ------------- Descarga directa -------- */
+  const streamBtn = document.getElementById("streamBtn");
+  streamBtn.onclick = () => {
+    const url = urlInput.value.trim();
+    if (!url) return toast("Introduce la URL", false);
+    if (typeSel.value !== "video" && typeSel.value !== "audio") return toast("Solo video o audio", false);
+    /* formulario POST: el navegador gestiona la descarga del stream */
+    const form = document.createElement("form");
+    form.method = "POST";
+    form.action = "./stream";
+    const fields = { url, type: typeSel.value, quality: qualitySel.value, cookies: getCookies() };
+    for (const [k, v] of Object.entries(fields)) {
+      const inp = document.createElement("input");
+      inp.type = "hidden"; inp.name = k; inp.value = v;
+      form.append(inp);
+    }
+    document.body.append(form);
+    form.submit();
+    form.remove();
+  };
+
   actionBtn.dataset.mode = "start";
   actionBtn.onclick = () => actionBtn.dataset.mode === "start" ? startDownload() : cancelDownload();
 });
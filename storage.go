@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*              almacenamiento de archivos terminados (local / S3)            */
+/* -------------------------------------------------------------------------- */
+
+/*
+Las claves son "<job id>/<nombre en el manifest>", es decir la ruta
+relativa a downloadDir: el backend local no necesita copiar nada
+*/
+type Storage interface {
+	Put(ctx context.Context, key, localPath string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+/* backends remotos: pueden entregar por redirección o por proxy */
+type remoteStorage interface {
+	Storage
+	Exists(ctx context.Context, key string) (bool, error)
+	PresignedURL(key, name string, ttl time.Duration) (string, error)
+	Serve(w http.ResponseWriter, r *http.Request, key, name string) error
+}
+
+type localStorage struct{ root string }
+
+func (l localStorage) path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(key))
+}
+
+func (l localStorage) Put(_ context.Context, key, localPath string) error {
+	dst := l.path(key)
+	if filepath.Clean(localPath) == filepath.Clean(dst) {
+		return nil // ya está en su sitio
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (l localStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(l.path(key))
+}
+
+func (l localStorage) Delete(_ context.Context, key string) error {
+	return os.Remove(l.path(key))
+}
+
+var (
+	storage         Storage = localStorage{root: downloadDir}
+	storageRedirect         = true  // remoto: 302 a URL prefirmada (false = proxy)
+	storageKeep             = false // remoto: conservar la copia local
+)
+
+const presignTTL = 15 * time.Minute
+
+/*
+configura el backend desde el entorno:
+
+	STORAGE=s3 S3_ENDPOINT=http://minio:9000 S3_BUCKET=yt S3_ACCESS_KEY=… S3_SECRET_KEY=…
+	[S3_REGION] [S3_PREFIX] [S3_PATH_STYLE=0] [S3_DELIVERY=redirect|proxy] [S3_KEEP_LOCAL=1]
+
+(cómo probarlo contra MinIO: s3_test.go)
+*/
+func initStorage() error {
+	switch strings.ToLower(os.Getenv("STORAGE")) {
+	case "", "local":
+		return nil
+	case "s3":
+	default:
+		return fmt.Errorf("STORAGE desconocido: %s", os.Getenv("STORAGE"))
+	}
+
+	pathStyle := os.Getenv("S3_PATH_STYLE") == "" || formBool(os.Getenv("S3_PATH_STYLE"))
+	s3, err := newS3Storage(
+		os.Getenv("S3_ENDPOINT"), os.Getenv("S3_REGION"), os.Getenv("S3_BUCKET"),
+		os.Getenv("S3_PREFIX"), os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"),
+		pathStyle)
+	if err != nil {
+		return err
+	}
+	storage = s3
+	storageRedirect = strings.ToLower(os.Getenv("S3_DELIVERY")) != "proxy"
+	storageKeep = formBool(os.Getenv("S3_KEEP_LOCAL"))
+	log.Printf("almacenamiento: s3 %s/%s", s3.endpoint.Host, s3.bucket)
+	return nil
+}
+
+func storageKey(id, name string) string { return id + "/" + name }
+
+/* sube los archivos del job; en remoto borra la copia local salvo S3_KEEP_LOCAL */
+func storeOutputs(id, dest string, files []outputFile) error {
+	if _, ok := storage.(remoteStorage); !ok {
+		return nil
+	}
+	ctx := context.Background()
+	for _, f := range files {
+		if f.Key != storageKey(id, f.Name) {
+			continue // mismo contenido ya subido por otro job
+		}
+		if err := storage.Put(ctx, f.Key, f.Path); err != nil {
+			return fmt.Errorf("subiendo %s: %v", f.Name, err)
+		}
+	}
+	if !storageKeep {
+		os.RemoveAll(dest)
+	}
+	return nil
+}
+
+/* abre un archivo del job desde donde esté guardado */
+func openOutput(f outputFile) (io.ReadCloser, error) {
+	return storage.Open(context.Background(), f.Key)
+}
+
+/* entrega un archivo del job: local con Range/ETag, remoto por 302 o proxy */
+func serveOutput(w http.ResponseWriter, r *http.Request, f outputFile) {
+	if servedLocally(f) {
+		serveDownload(w, r, f.Path)
+		return
+	}
+	rs := storage.(remoteStorage)
+	name := filepath.Base(f.Name)
+	if storageRedirect {
+		u, err := rs.PresignedURL(f.Key, name, presignTTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+		return
+	}
+	if err := rs.Serve(w, r, f.Key, name); err != nil {
+		log.Printf("storage %s: %v", f.Key, err)
+	}
+}
+
+/* ¿serveOutput envía f desde el disco? */
+func servedLocally(f outputFile) bool {
+	if _, ok := storage.(remoteStorage); !ok {
+		return true
+	}
+	if !storageKeep {
+		return false
+	}
+	_, err := os.Stat(f.Path)
+	return err == nil
+}
+
+/* tamaño para useShare; -1 si se redirige: la URL prefirmada admite cualquier Range */
+func shareSize(f outputFile) int64 {
+	if storageRedirect && !servedLocally(f) {
+		return -1
+	}
+	return f.Size
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                    persistencia JSON sencilla en dataDir                   */
+/* -------------------------------------------------------------------------- */
+
+/* lee dataDir/name en v; un archivo inexistente no es error */
+func loadJSON(name string, v any) error {
+	b, err := os.ReadFile(filepath.Join(dataDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+/* escribe v en dataDir/name de forma atómica (tmp + rename) */
+func saveJSON(name string, v any) error {
+	path := filepath.Join(dataDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
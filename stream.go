@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* -------------------------------------------------------------------------- */
+/*          /stream: yt-dlp → stdout → respuesta HTTP, sin tocar disco        */
+/* -------------------------------------------------------------------------- */
+
+/* solo formatos de un archivo (sin merge ni post-proceso) */
+func streamFormat(media, quality string) (string, error) {
+	quality, err := parseQuality(quality)
+	if err != nil {
+		return "", err
+	}
+	switch media {
+	case "", "video":
+		if quality != "" {
+			return fmt.Sprintf("b[ext=mp4][height<=%s]/b[height<=%s]/b", quality, quality), nil
+		}
+		return "b[ext=mp4]/b", nil
+	case "audio":
+		if quality != "" {
+			return fmt.Sprintf("ba[ext=m4a][abr<=%s]/ba[abr<=%s]/ba", quality, quality), nil
+		}
+		return "ba[ext=m4a]/ba", nil
+	}
+	return "", fmt.Errorf("streaming solo para video o audio")
+}
+
+/*
+lanza yt-dlp con -o - y copia su salida a w. Las cabeceras se envían al
+recibir el primer bloque, cuando yt-dlp ya informó título y extensión.
+Al cancelarse ctx (cliente desconectado) el proceso muere. status != 0
+indica un error antes de enviar nada
+*/
+func streamMedia(ctx context.Context, w http.ResponseWriter, url, rawCookies, media, quality string) (int, error) {
+	format, err := streamFormat(media, quality)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "ytstream_")
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cookieFile, clean, err := prepareCookieFile(rawCookies, tmpDir)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	defer clean()
+
+	metaFile := filepath.Join(tmpDir, "meta")
+	args := []string{
+		"-f", format, "-o", "-",
+		"--no-playlist", "--no-part", "--no-warnings",
+		"--print-to-file", "before_dl:%(title)s\t%(ext)s", metaFile,
+	}
+	if cookieFile != "" {
+		args = append(args, "--cookies", cookieFile)
+	}
+	args = append(args, url)
+
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := cmd.Start(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	/* primer bloque: confirma que hay datos antes de comprometer cabeceras */
+	first := make([]byte, 64*1024)
+	n, rerr := io.ReadFull(stdout, first)
+	if n == 0 {
+		werr := cmd.Wait()
+		if werr == nil {
+			werr = rerr
+		}
+		return http.StatusBadGateway, fmt.Errorf("%v – %s", werr, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	title, ext := "video", "mp4"
+	if b, err := os.ReadFile(metaFile); err == nil {
+		t, e, _ := strings.Cut(strings.TrimSpace(string(b)), "\t")
+		if t != "" {
+			title = t
+		}
+		if e != "" {
+			ext = e
+		}
+	}
+	name := sanitizeFilename(title) + "." + ext
+	w.Header().Set("Content-Type", mimeFor(name))
+	w.Header().Set("Content-Disposition", contentDisposition(name))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(first[:n]); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, err
+	}
+	if !errors.Is(rerr, io.ErrUnexpectedEOF) && !errors.Is(rerr, io.EOF) {
+		if _, err := io.Copy(w, stdout); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return 0, err
+		}
+	}
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		return 0, fmt.Errorf("%v – %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return 0, nil
+}
+
+/* ---------------------------  /stream GET|POST ---------------------------- */
+
+func streamGin(c *gin.Context) {
+	url := c.Request.FormValue("url")
+	if url == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url requerida"})
+		return
+	}
+	status, err := streamMedia(c.Request.Context(), c.Writer, url,
+		c.Request.FormValue("cookies"), c.Request.FormValue("type"), c.Request.FormValue("quality"))
+	if status != 0 {
+		c.JSON(status, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                 suscripciones a canales / playlists                        */
+/* -------------------------------------------------------------------------- */
+
+const (
+	subsFile        = "subscriptions.json"
+	subsArchiveDir  = "subs" // dataDir/subs/<id>.archive
+	defaultInterval = 60     // minutos
+	defaultMaxItems = 10     // entradas recientes a revisar por chequeo
+)
+
+type subFilters struct {
+	MatchTitle  string `json:"match_title,omitempty"`  // regex que el título debe cumplir
+	RejectTitle string `json:"reject_title,omitempty"` // regex que descarta el título
+	MinDuration int    `json:"min_duration,omitempty"` // segundos
+	MaxDuration int    `json:"max_duration,omitempty"` // segundos
+}
+
+type subscription struct {
+	ID          string            `json:"id"`
+	URL         string            `json:"url"`
+	Options     jobOptions        `json:"options"`
+	Interval    int               `json:"interval"` // minutos
+	MaxItems    int               `json:"max_items"`
+	Filters     subFilters        `json:"filters"`
+	CreatedAt   time.Time         `json:"created_at"`
+	LastChecked time.Time         `json:"last_checked"`
+	LastError   string            `json:"last_error"`
+	Pending     map[string]string `json:"pending,omitempty"` // job id → clave del archivo
+}
+
+var (
+	subs     = make(map[string]*subscription)
+	subsBusy = make(map[string]bool) // chequeos en curso
+	subsMu   sync.Mutex
+)
+
+/* copia segura para serializar fuera del lock */
+func (s *subscription) snapshot() subscription {
+	cp := *s
+	cp.Pending = make(map[string]string, len(s.Pending))
+	for k, v := range s.Pending {
+		cp.Pending[k] = v
+	}
+	return cp
+}
+
+/* true si la entrada pasa los filtros de la suscripción */
+func (f subFilters) accept(title string, duration float64) bool {
+	if f.MatchTitle != "" {
+		if ok, _ := regexp.MatchString("(?i)"+f.MatchTitle, title); !ok {
+			return false
+		}
+	}
+	if f.RejectTitle != "" {
+		if ok, _ := regexp.MatchString("(?i)"+f.RejectTitle, title); ok {
+			return false
+		}
+	}
+	// duración desconocida (0) no se filtra
+	if duration > 0 {
+		if f.MinDuration > 0 && duration < float64(f.MinDuration) {
+			return false
+		}
+		if f.MaxDuration > 0 && duration > float64(f.MaxDuration) {
+			return false
+		}
+	}
+	return true
+}
+
+/* ------------------------------ persistencia ------------------------------ */
+
+func loadSubscriptions() error {
+	var list []*subscription
+	if err := loadJSON(subsFile, &list); err != nil {
+		return err
+	}
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	for _, s := range list {
+		if s.Pending == nil {
+			s.Pending = map[string]string{}
+		}
+		subs[s.ID] = s
+	}
+	return nil
+}
+
+/* requiere subsMu tomado */
+func saveSubscriptionsLocked() error {
+	list := make([]*subscription, 0, len(subs))
+	for _, s := range subs {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return saveJSON(subsFile, list)
+}
+
+func subArchivePath(id string) string {
+	return filepath.Join(dataDir, subsArchiveDir, id+".archive")
+}
+
+func readSubArchive(id string) (map[string]bool, error) {
+	return readArchiveFile(subArchivePath(id))
+}
+
+func appendSubArchive(id, key string) error {
+	return appendArchiveFile(subArchivePath(id), key)
+}
+
+/* ------------------------------ alta / baja ------------------------------- */
+
+func createSubscription(form func(string) string) (subscription, error) {
+	url := form("url")
+	if url == "" {
+		return subscription{}, errors.New("url requerida")
+	}
+	atoi := func(key string, def int) (int, error) {
+		v := form(key)
+		if v == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s inválido", key)
+		}
+		return n, nil
+	}
+
+	opts, err := parseJobOptions(form)
+	if err != nil {
+		return subscription{}, err
+	}
+
+	s := &subscription{
+		ID:        uuid.New().String(),
+		URL:       url,
+		Options:   opts,
+		CreatedAt: time.Now(),
+		Pending:   map[string]string{},
+		Filters: subFilters{
+			MatchTitle:  form("match_title"),
+			RejectTitle: form("reject_title"),
+		},
+	}
+	if s.Interval, err = atoi("interval", defaultInterval); err != nil {
+		return subscription{}, err
+	}
+	if s.Interval == 0 {
+		s.Interval = defaultInterval
+	}
+	if s.MaxItems, err = atoi("max_items", defaultMaxItems); err != nil {
+		return subscription{}, err
+	}
+	if s.Filters.MinDuration, err = atoi("min_duration", 0); err != nil {
+		return subscription{}, err
+	}
+	if s.Filters.MaxDuration, err = atoi("max_duration", 0); err != nil {
+		return subscription{}, err
+	}
+	for _, re := range []string{s.Filters.MatchTitle, s.Filters.RejectTitle} {
+		if _, err := regexp.Compile(re); err != nil {
+			return subscription{}, fmt.Errorf("filtro inválido: %v", err)
+		}
+	}
+
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	subs[s.ID] = s
+	if err := saveSubscriptionsLocked(); err != nil {
+		delete(subs, s.ID)
+		return subscription{}, err
+	}
+	return s.snapshot(), nil
+}
+
+func deleteSubscription(id string) bool {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	if _, ok := subs[id]; !ok {
+		return false
+	}
+	delete(subs, id)
+	if err := saveSubscriptionsLocked(); err != nil {
+		log.Printf("suscripciones: %v", err)
+	}
+	os.Remove(subArchivePath(id))
+	return true
+}
+
+func getSubscription(id string) (subscription, bool) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	s, ok := subs[id]
+	if !ok {
+		return subscription{}, false
+	}
+	return s.snapshot(), true
+}
+
+func listSubscriptions() []subscription {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	list := make([]subscription, 0, len(subs))
+	for _, s := range subs {
+		list = append(list, s.snapshot())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+/* -------------------------------------------------------------------------- */
+/*                                 scheduler                                  */
+/* -------------------------------------------------------------------------- */
+
+type flatEntry struct {
+	ID       string  `json:"id"`
+	URL      string  `json:"url"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+	IEKey    string  `json:"ie_key"`
+}
+
+/* enumera las últimas entradas del canal / playlist sin descargar nada */
+func listPlaylistEntries(url string, maxItems int) ([]flatEntry, error) {
+	args := []string{"-J", "--flat-playlist", "--no-warnings"}
+	if maxItems > 0 {
+		args = append(args, "--playlist-end", strconv.Itoa(maxItems))
+	}
+	args = append(args, url)
+
+	out, err := exec.Command("yt-dlp", args...).Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return nil, fmt.Errorf("%v – %s", err, bytes.TrimSpace(ee.Stderr))
+		}
+		return nil, err
+	}
+	var pl struct {
+		Entries []flatEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(out, &pl); err != nil {
+		return nil, err
+	}
+	return pl.Entries, nil
+}
+
+/* clave estilo --download-archive: "youtube dQw4w9WgXcQ" */
+func archiveKey(e flatEntry) string {
+	ie := strings.ToLower(e.IEKey)
+	if ie == "" {
+		ie = "generic"
+	}
+	return ie + " " + e.ID
+}
+
+/* revisa una suscripción: liquida los jobs pendientes y encola lo nuevo */
+func checkSubscription(id string) error {
+	subsMu.Lock()
+	s, ok := subs[id]
+	if !ok {
+		subsMu.Unlock()
+		return errors.New("suscripción no encontrada")
+	}
+	if subsBusy[id] {
+		subsMu.Unlock()
+		return nil
+	}
+	subsBusy[id] = true
+	snap := s.snapshot()
+	subsMu.Unlock()
+
+	defer func() {
+		subsMu.Lock()
+		delete(subsBusy, id)
+		subsMu.Unlock()
+	}()
+
+	/* jobs de chequeos anteriores: los completados pasan al archivo */
+	pending := snap.Pending
+	for jobID, key := range pending {
+		ready, failed, exists := jobStatus(jobID)
+		if !exists {
+			/* tras un reinicio: si terminó bien, la biblioteca lo guardó */
+			ready = len(libraryJobOutputs(jobID)) > 0
+		}
+		switch {
+		case ready:
+			if err := appendSubArchive(id, key); err != nil {
+				return recordSubCheck(id, pending, err)
+			}
+			delete(pending, jobID)
+		case failed || !exists:
+			delete(pending, jobID) // se reintentará en el próximo chequeo
+		}
+	}
+
+	archive, err := readSubArchive(id)
+	if err != nil {
+		return recordSubCheck(id, pending, err)
+	}
+	/* lo bajado por otras vías en el mismo perfil tampoco se repite */
+	if p := snap.Options.Profile; p != "" && !snap.Options.Force {
+		done, err := readArchiveFile(profileArchivePath(p))
+		if err != nil {
+			return recordSubCheck(id, pending, err)
+		}
+		for k := range done {
+			archive[k] = true
+		}
+	}
+	inFlight := map[string]bool{}
+	for _, key := range pending {
+		inFlight[key] = true
+	}
+
+	/* subscriptions.json de antes de validar type o editado a mano */
+	if !mediaTypes[snap.Options.Media] {
+		return recordSubCheck(id, pending, fmt.Errorf("opciones guardadas inválidas: tipo no soportado: %s", snap.Options.Media))
+	}
+	entries, err := listPlaylistEntries(snap.URL, snap.MaxItems)
+	if err != nil {
+		return recordSubCheck(id, pending, err)
+	}
+	/* una entrada que no se puede encolar no frena al resto: queda en last_error */
+	var errs []error
+	for _, e := range entries {
+		if e.ID == "" {
+			continue
+		}
+		key := archiveKey(e)
+		if archive[key] || inFlight[key] || !snap.Filters.accept(e.Title, e.Duration) {
+			continue
+		}
+		url := e.URL
+		if url == "" {
+			url = e.ID
+		}
+		jobID, _, _, err := newJob(url, "", snap.Options)
+		if errors.Is(err, errArchived) {
+			continue // llegó al archivo del perfil después de leerlo
+		}
+		if err != nil {
+			log.Printf("suscripción %s: entrada %s: %v", id, e.ID, err)
+			errs = append(errs, fmt.Errorf("%s: %w", e.ID, err))
+			continue
+		}
+		pending[jobID] = key
+		inFlight[key] = true
+	}
+	return recordSubCheck(id, pending, errors.Join(errs...))
+}
+
+/* guarda el resultado del chequeo (last_checked / last_error) */
+func recordSubCheck(id string, pending map[string]string, err error) error {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	s, ok := subs[id]
+	if !ok {
+		return err // borrada mientras se chequeaba
+	}
+	s.LastChecked = time.Now()
+	s.LastError = ""
+	if err != nil {
+		s.LastError = err.Error()
+	}
+	s.Pending = pending
+	if serr := saveSubscriptionsLocked(); serr != nil {
+		log.Printf("suscripciones: %v", serr)
+	}
+	return err
+}
+
+/* carga las suscripciones y revisa cada minuto las que toca */
+func startSubscriptionScheduler() {
+	if err := loadSubscriptions(); err != nil {
+		log.Printf("suscripciones: %v", err)
+	}
+	go func() {
+		for {
+			var due []string
+			subsMu.Lock()
+			for id, s := range subs {
+				if time.Since(s.LastChecked) >= time.Duration(s.Interval)*time.Minute {
+					due = append(due, id)
+				}
+			}
+			subsMu.Unlock()
+
+			for _, id := range due {
+				if err := checkSubscription(id); err != nil {
+					log.Printf("suscripción %s: %v", id, err)
+				}
+			}
+			time.Sleep(time.Minute)
+		}
+	}()
+}
+
+/* -------------------------------------------------------------------------- */
+/*                                  rutas                                     */
+/* -------------------------------------------------------------------------- */
+
+func listSubscriptionsGin(c *gin.Context) {
+	c.JSON(http.StatusOK, listSubscriptions())
+}
+
+func createSubscriptionGin(c *gin.Context) {
+	s, err := createSubscription(c.PostForm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	go checkSubscription(s.ID)
+	c.JSON(http.StatusOK, s)
+}
+
+func deleteSubscriptionGin(c *gin.Context) {
+	if !deleteSubscription(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "suscripción no encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func checkSubscriptionGin(c *gin.Context) {
+	id := c.Param("id")
+	if err := checkSubscription(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s, ok := getSubscription(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "suscripción no encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
@@ -0,0 +1,52 @@
+package subtitle
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                         JSON3 (timedtext de YouTube)                       */
+/* -------------------------------------------------------------------------- */
+
+type json3Doc struct {
+	Events []struct {
+		StartMs    int64 `json:"tStartMs"`
+		DurationMs int64 `json:"dDurationMs"`
+		Segs       []struct {
+			UTF8 string `json:"utf8"`
+		} `json:"segs"`
+	} `json:"events"`
+}
+
+/* un fragmento por evento con texto; los eventos solo de "\n" se saltan */
+func parseJSON3(data []byte) ([]Cue, error) {
+	var doc json3Doc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var cues []Cue
+	for _, e := range doc.Events {
+		var b strings.Builder
+		for _, s := range e.Segs {
+			b.WriteString(s.UTF8)
+		}
+		var lines []string
+		for _, l := range strings.Split(b.String(), "\n") {
+			if l = cleanLine(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		start := time.Duration(e.StartMs) * time.Millisecond
+		cues = append(cues, Cue{
+			Start: start,
+			End:   start + time.Duration(e.DurationMs)*time.Millisecond,
+			Text:  strings.Join(lines, "\n"),
+		})
+	}
+	return cues, nil
+}
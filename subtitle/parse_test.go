@@ -0,0 +1,169 @@
+package subtitle
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ms(n int64) time.Duration { return time.Duration(n) * time.Millisecond }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   []Cue
+	}{
+		{
+			name:   "srt con BOM, CRLF y etiquetas",
+			format: "srt",
+			data: "\ufeff1\r\n00:00:01,000 --> 00:00:02,500\r\n<i>Hola</i>\r\nmundo\r\n\r\n" +
+				"2\r\n01:00:00,250 --> 01:00:03,000\r\nFish &amp; chips\r\n",
+			want: []Cue{
+				{ms(1000), ms(2500), "Hola\nmundo"},
+				{time.Hour + ms(250), time.Hour + ms(3000), "Fish & chips"},
+			},
+		},
+		{
+			name:   "vtt con cabecera, NOTE, STYLE y ajustes",
+			format: "vtt",
+			data: "WEBVTT\nKind: captions\n\nNOTE algo\n\nSTYLE\n::cue { color: red }\n\n" +
+				"cue-1\n00:01.000 --> 00:02.000 align:start position:0%\n<v Ana>Hola</v>\n\n" +
+				"00:00:03.500 --> 00:00:04.000\nadiós\n",
+			want: []Cue{
+				{ms(1000), ms(2000), "Hola"},
+				{ms(3500), ms(4000), "adiós"},
+			},
+		},
+		{
+			name:   "ttml con reloj, segundos y dur",
+			format: "ttml",
+			data: `<?xml version="1.0"?><tt xmlns="http://www.w3.org/ns/ttml"><body><div>
+				<p begin="00:00:01.000" end="00:00:02.000">uno<br/>dos</p>
+				<p begin="3s" dur="1500ms"><span>tres</span>   cuatro</p>
+			</div></body></tt>`,
+			want: []Cue{
+				{ms(1000), ms(2000), "uno\ndos"},
+				{ms(3000), ms(4500), "tres cuatro"},
+			},
+		},
+		{
+			name:   "ttml con fotogramas",
+			format: "ttml",
+			data: `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:frameRate="25"><body><div>
+				<p begin="00:00:01:12" end="00:00:02:00">a</p>
+				<p begin="50f" end="75f">b</p>
+			</div></body></tt>`,
+			want: []Cue{
+				{ms(1480), ms(2000), "a"},
+				{ms(2000), ms(3000), "b"},
+			},
+		},
+		{
+			name:   "ttml con ticks",
+			format: "ttml",
+			data: `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:tickRate="10000000"><body><div>
+				<p begin="15000000t" end="25000000t">tic</p>
+			</div></body></tt>`,
+			want: []Cue{{ms(1500), ms(2500), "tic"}},
+		},
+		{
+			name:   "srv3 sin ventanas vacías",
+			format: "srv3",
+			data: `<?xml version="1.0" encoding="utf-8" ?><timedtext format="3"><body>
+				<w t="0" id="1" wp="1"/>
+				<p t="1000" d="2000" w="1"><s>hola</s><s t="400"> mundo</s></p>
+				<p t="3000" d="10" w="1" a="1">
+</p>
+				<p t="3010" d="1500">It&amp;#39;s</p>
+			</body></timedtext>`,
+			want: []Cue{
+				{ms(1000), ms(3000), "hola mundo"},
+				{ms(3010), ms(4510), "It's"},
+			},
+		},
+		{
+			name:   "srv2",
+			format: "srv2",
+			data:   `<timedtext><text t="500" d="1200">hola</text><text t="1700" d="300">adiós</text></timedtext>`,
+			want: []Cue{
+				{ms(500), ms(1700), "hola"},
+				{ms(1700), ms(2000), "adiós"},
+			},
+		},
+		{
+			name:   "srv1",
+			format: "srv1",
+			data:   `<?xml version="1.0"?><transcript><text start="0.5" dur="1.25">hola</text><text start="2" dur="1">it&amp;#39;s</text></transcript>`,
+			want: []Cue{
+				{ms(500), ms(1750), "hola"},
+				{ms(2000), ms(3000), "it's"},
+			},
+		},
+		{
+			name:   "json3 sin eventos de solo salto",
+			format: "json3",
+			data: `{"events":[
+				{"tStartMs":0,"dDurationMs":5000,"id":1,"wpWinPosId":1},
+				{"tStartMs":160,"dDurationMs":2590,"segs":[{"utf8":"hello"},{"utf8":" everyone","tOffsetMs":320}]},
+				{"tStartMs":2750,"dDurationMs":10,"aAppend":1,"segs":[{"utf8":"\n"}]},
+				{"tStartMs":2760,"dDurationMs":2350,"segs":[{"utf8":"to the\nchannel"}]}
+			]}`,
+			want: []Cue{
+				{ms(160), ms(2750), "hello everyone"},
+				{ms(2760), ms(5110), "to the\nchannel"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, format, data string
+	}{
+		{"tiempos srt", "srt", "1\n00:00:01,000 -->\nx\n"},
+		{"reloj vtt", "vtt", "WEBVTT\n\n00:xx.000 --> 00:02.000\nx\n"},
+		{"ttml sin end ni dur", "ttml", `<tt><body><p begin="1s">x</p></body></tt>`},
+		{"ttml unidad desconocida", "ttml", `<tt><body><p begin="1q" end="2s">x</p></body></tt>`},
+		{"srv3 sin d", "srv3", `<timedtext><body><p t="1">x</p></body></timedtext>`},
+		{"json3 roto", "json3", `{"events":[`},
+		{"formato", "sbv", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data), tt.format); err == nil {
+				t.Error("se esperaba error")
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		"a.en.vtt": "vtt", "a.en.WEBVTT": "vtt", "a.srt": "srt", "a.es.ttml": "ttml",
+		"a.xml": "ttml", "a.dfxp": "ttml", "a.srv3": "srv3", "a.srv2": "srv2", "a.srv1": "srv1",
+		"a.json3": "json3", "a.ass": "", "a": "",
+	}
+	for name, want := range tests {
+		if got := Detect(name); got != want {
+			t.Errorf("Detect(%q) = %q, se esperaba %q", name, got, want)
+		}
+	}
+	for _, ext := range Extensions() {
+		if Detect("a."+ext) == "" {
+			t.Errorf("Extensions incluye %q pero Detect no lo reconoce", ext)
+		}
+	}
+}
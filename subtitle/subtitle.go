@@ -0,0 +1,154 @@
+/*
+Package subtitle lee subtítulos SRT, WebVTT, TTML y los JSON3/SRV1-3 de
+YouTube a un modelo común (una lista de Cue) y los escribe como SRT, VTT
+o ASS, sin depender de ffmpeg
+*/
+package subtitle
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* fragmento de texto con su intervalo; las líneas se separan con "\n" */
+type Cue struct {
+	Start, End time.Duration
+	Text       string
+}
+
+/* formatos de entrada por extensión */
+var Inputs = []string{"vtt", "srt", "ttml", "json3", "srv3", "srv2", "srv1"}
+
+/* otras extensiones con las que llegan esos formatos */
+var aliases = map[string]string{"xml": "ttml", "dfxp": "ttml", "webvtt": "vtt"}
+
+/* todas las extensiones que Detect reconoce */
+func Extensions() []string {
+	out := append([]string(nil), Inputs...)
+	for ext := range aliases {
+		out = append(out, ext)
+	}
+	sort.Strings(out)
+	return out
+}
+
+/* formatos de salida */
+var Outputs = []string{"srt", "vtt", "ass"}
+
+/* formato según la extensión de name ("" si no se sabe leer) */
+func Detect(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if f, ok := aliases[ext]; ok {
+		return f
+	}
+	for _, f := range Inputs {
+		if ext == f {
+			return f
+		}
+	}
+	return ""
+}
+
+/* interpreta data en el formato indicado (uno de Inputs) */
+func Parse(data []byte, format string) ([]Cue, error) {
+	switch format {
+	case "srt", "vtt":
+		return parseText(string(data))
+	case "ttml":
+		return parseTTML(data)
+	case "srv3", "srv2":
+		return parseSRV3(data)
+	case "srv1":
+		return parseSRV1(data)
+	case "json3":
+		return parseJSON3(data)
+	}
+	return nil, fmt.Errorf("formato de subtítulos no soportado: %s", format)
+}
+
+/* adelanta (d < 0) o retrasa los tiempos; lo que queda antes de 0 se pierde */
+func Shift(cues []Cue, d time.Duration) []Cue {
+	out := make([]Cue, 0, len(cues))
+	for _, c := range cues {
+		c.Start += d
+		c.End += d
+		if c.End <= 0 {
+			continue
+		}
+		if c.Start < 0 {
+			c.Start = 0
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+/* separación máxima para unir dos fragmentos seguidos con el mismo texto */
+const mergeGap = 100 * time.Millisecond
+
+/*
+limpia la lista: sin fragmentos vacíos y uniendo los consecutivos con el
+mismo texto. Si son automáticos "rodantes" de YouTube (cada fragmento
+repite la última línea del anterior) se quitan además las repeticiones
+*/
+func Merge(cues []Cue) []Cue {
+	rolling := Rolling(cues)
+	var out []Cue
+	prev := ""
+	for _, c := range cues {
+		if rolling {
+			var lines []string
+			for _, l := range strings.Split(c.Text, "\n") {
+				if l = strings.TrimSpace(l); l != "" && l != prev {
+					lines = append(lines, l)
+					prev = l
+				}
+			}
+			c.Text = strings.Join(lines, "\n")
+		}
+		if strings.TrimSpace(c.Text) == "" {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Text == c.Text && c.Start-out[n-1].End <= mergeGap {
+			if c.End > out[n-1].End {
+				out[n-1].End = c.End
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+/* la mayoría de fragmentos de varias líneas empiezan con la última del anterior */
+func Rolling(cues []Cue) bool {
+	multi, repeats := 0, 0
+	last := ""
+	for _, c := range cues {
+		lines := nonEmptyLines(c.Text)
+		if len(lines) == 0 {
+			continue
+		}
+		if len(lines) > 1 {
+			multi++
+			if lines[0] == last {
+				repeats++
+			}
+		}
+		last = lines[len(lines)-1]
+	}
+	return multi > 0 && repeats*2 > multi
+}
+
+func nonEmptyLines(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
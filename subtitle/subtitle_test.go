@@ -0,0 +1,162 @@
+package subtitle
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShift(t *testing.T) {
+	cues := []Cue{
+		{ms(500), ms(1500), "a"},
+		{ms(2000), ms(3000), "b"},
+		{ms(4000), ms(5000), "c"},
+	}
+	tests := []struct {
+		name string
+		d    time.Duration
+		want []Cue
+	}{
+		{"sin desfase", 0, cues},
+		{"retrasar", ms(1000), []Cue{
+			{ms(1500), ms(2500), "a"},
+			{ms(3000), ms(4000), "b"},
+			{ms(5000), ms(6000), "c"},
+		}},
+		{"adelantar recorta y descarta", -ms(2500), []Cue{
+			{0, ms(500), "b"},
+			{ms(1500), ms(2500), "c"},
+		}},
+		{"todo antes de cero", -ms(6000), []Cue{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Shift(cues, tt.d); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Shift:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name        string
+		cues        []Cue
+		wantRolling bool
+		want        []Cue
+	}{
+		{
+			name: "une repetidos seguidos y quita vacíos",
+			cues: []Cue{
+				{ms(0), ms(1000), "hola"},
+				{ms(1050), ms(2000), "hola"},
+				{ms(2000), ms(2500), "  "},
+				{ms(3000), ms(4000), "hola"}, // hueco mayor que mergeGap
+			},
+			want: []Cue{
+				{ms(0), ms(2000), "hola"},
+				{ms(3000), ms(4000), "hola"},
+			},
+		},
+		{
+			name: "varias líneas sin repetir no es rodante",
+			cues: []Cue{
+				{ms(0), ms(1000), "uno\ndos"},
+				{ms(1000), ms(2000), "tres\ncuatro"},
+			},
+			want: []Cue{
+				{ms(0), ms(1000), "uno\ndos"},
+				{ms(1000), ms(2000), "tres\ncuatro"},
+			},
+		},
+		{
+			name: "rodante",
+			cues: []Cue{
+				{ms(0), ms(1000), "uno"},
+				{ms(1000), ms(2000), "uno\ndos"},
+				{ms(2000), ms(3000), "dos\ntres"},
+			},
+			wantRolling: true,
+			want: []Cue{
+				{ms(0), ms(1000), "uno"},
+				{ms(1000), ms(2000), "dos"},
+				{ms(2000), ms(3000), "tres"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rolling(tt.cues); got != tt.wantRolling {
+				t.Errorf("Rolling = %v, se esperaba %v", got, tt.wantRolling)
+			}
+			if got := Merge(tt.cues); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+/* subtítulos automáticos de YouTube tal como los baja yt-dlp en VTT */
+func TestMergeYouTubeRolling(t *testing.T) {
+	b, err := os.ReadFile("testdata/rolling.en.vtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues, err := Parse(b, Detect("rolling.en.vtt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cues) != 6 || !Rolling(cues) {
+		t.Fatalf("se esperaban 6 fragmentos rodantes, hay %d (rolling=%v)", len(cues), Rolling(cues))
+	}
+	want := []Cue{
+		{ms(160), ms(2750), "hello everyone and welcome"},
+		{ms(2760), ms(5110), "to the channel"},
+		{ms(5120), ms(7430), "today we talk about Go"},
+	}
+	if got := Merge(cues); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cues := []Cue{
+		{ms(1000), ms(2500), "hola\n{mundo}"},
+		{time.Hour + ms(10), time.Hour + ms(2000), "adiós"},
+	}
+	tests := []struct {
+		format, want string
+	}{
+		{"srt", "1\n00:00:01,000 --> 00:00:02,500\nhola\n{mundo}\n\n" +
+			"2\n01:00:00,010 --> 01:00:02,000\nadiós\n\n"},
+		{"vtt", "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nhola\n{mundo}\n\n" +
+			"01:00:00.010 --> 01:00:02.000\nadiós\n\n"},
+		{"ass", assHeader +
+			"Dialogue: 0,0:00:01.00,0:00:02.50,Default,,0,0,0,,hola\\N(mundo)\n" +
+			"Dialogue: 0,1:00:00.01,1:00:02.00,Default,,0,0,0,,adiós\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := Write(&b, cues, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("Write:\n got %q\nwant %q", b.String(), tt.want)
+			}
+			/* SRT y VTT se vuelven a leer igual */
+			if tt.format == "ass" {
+				return
+			}
+			back, err := Parse(b.Bytes(), tt.format)
+			if err != nil || !reflect.DeepEqual(back, cues) {
+				t.Errorf("ida y vuelta: %q, %v", back, err)
+			}
+		})
+	}
+	if err := Write(&bytes.Buffer{}, cues, "sbv"); err == nil {
+		t.Error("se esperaba error con un formato de salida desconocido")
+	}
+}
@@ -0,0 +1,86 @@
+package subtitle
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                               SRT y WebVTT                                 */
+/* -------------------------------------------------------------------------- */
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+/* "01:02:03,456" (SRT) o "02:03.456" (VTT) */
+func parseClock(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("tiempo inválido: %q", s)
+	}
+	var d time.Duration
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, fmt.Errorf("tiempo inválido: %q", s)
+		}
+		unit := time.Minute
+		switch len(parts) - 1 - i {
+		case 0:
+			unit = time.Second
+		case 2:
+			unit = time.Hour
+		}
+		d += time.Duration(v * float64(unit))
+	}
+	return d, nil
+}
+
+/* texto sin etiquetas de estilo ni tiempos por palabra, espacios normalizados */
+func cleanLine(s string) string {
+	s = html.UnescapeString(tagRe.ReplaceAllString(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+/*
+bloques separados por líneas en blanco; cada uno lleva "inicio --> fin"
+(en VTT seguido de ajustes de posición) y después el texto. Cabecera,
+NOTE y STYLE no tienen esa línea y se ignoran
+*/
+func parseText(data string) ([]Cue, error) {
+	data = strings.ReplaceAll(strings.TrimPrefix(data, "\ufeff"), "\r\n", "\n")
+	var cues []Cue
+	for _, block := range strings.Split(data, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		for i, l := range lines {
+			if !strings.Contains(l, "-->") {
+				continue
+			}
+			f := strings.Fields(l)
+			if len(f) < 3 || f[1] != "-->" {
+				return nil, fmt.Errorf("línea de tiempos inválida: %q", l)
+			}
+			start, err := parseClock(f[0])
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseClock(f[2])
+			if err != nil {
+				return nil, err
+			}
+			var text []string
+			for _, t := range lines[i+1:] {
+				if t = cleanLine(t); t != "" {
+					text = append(text, t)
+				}
+			}
+			cues = append(cues, Cue{Start: start, End: end, Text: strings.Join(text, "\n")})
+			break
+		}
+	}
+	return cues, nil
+}
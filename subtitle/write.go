@@ -0,0 +1,79 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                              SRT, VTT y ASS                                */
+/* -------------------------------------------------------------------------- */
+
+/* h, m, s y milisegundos de d (negativos como 0) */
+func clock(d time.Duration) (h, m, s, ms int64) {
+	if d < 0 {
+		d = 0
+	}
+	t := int64(d / time.Millisecond)
+	return t / 3600000, t / 60000 % 60, t / 1000 % 60, t % 1000
+}
+
+func srtTime(d time.Duration) string {
+	h, m, s, ms := clock(d)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
+
+func vttTime(d time.Duration) string {
+	h, m, s, ms := clock(d)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+/* ASS usa centésimas: h:mm:ss.cc */
+func assTime(d time.Duration) string {
+	h, m, s, ms := clock(d)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, ms/10)
+}
+
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+PlayResX: 384
+PlayResY: 288
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,16,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,1,0,2,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+/* escribe cues en el formato indicado (uno de Outputs) */
+func Write(w io.Writer, cues []Cue, format string) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "srt":
+		for i, c := range cues {
+			fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, srtTime(c.Start), srtTime(c.End), c.Text)
+		}
+	case "vtt":
+		bw.WriteString("WEBVTT\n\n")
+		for _, c := range cues {
+			fmt.Fprintf(bw, "%s --> %s\n%s\n\n", vttTime(c.Start), vttTime(c.End), c.Text)
+		}
+	case "ass":
+		bw.WriteString(assHeader)
+		for _, c := range cues {
+			/* las llaves abren bloques de estilo en ASS */
+			text := strings.NewReplacer("{", "(", "}", ")", "\n", `\N`).Replace(c.Text)
+			fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", assTime(c.Start), assTime(c.End), text)
+		}
+	default:
+		return fmt.Errorf("formato de salida no soportado: %s", format)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,195 @@
+package subtitle
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* -------------------------------------------------------------------------- */
+/*               TTML (W3C) y SRV1-3 (XML propios de YouTube)                 */
+/* -------------------------------------------------------------------------- */
+
+/* ritmos de TTML para tiempos en fotogramas ("f", "hh:mm:ss:ff") y ticks ("t") */
+type ttmlRates struct {
+	frame, tick float64
+}
+
+/*
+tiempo TTML: de reloj ("00:01:02.5", "00:01:02:12") o con unidad
+("62.5s", "1500ms", "1.5h", "30f", "10000000t")
+*/
+func parseTTMLTime(s string, r ttmlRates) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) == 4 { // hh:mm:ss:fotogramas
+			frames, err := strconv.ParseFloat(parts[3], 64)
+			if err != nil {
+				return 0, fmt.Errorf("tiempo inválido: %q", s)
+			}
+			d, err := parseClock(strings.Join(parts[:3], ":"))
+			return d + time.Duration(frames/r.frame*float64(time.Second)), err
+		}
+		return parseClock(s)
+	}
+	units := []struct {
+		suffix string
+		unit   float64
+	}{
+		{"ms", float64(time.Millisecond)}, {"h", float64(time.Hour)}, {"m", float64(time.Minute)},
+		{"s", float64(time.Second)}, {"f", float64(time.Second) / r.frame}, {"t", float64(time.Second) / r.tick},
+	}
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			v, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("tiempo inválido: %q", s)
+			}
+			return time.Duration(v * u.unit), nil
+		}
+	}
+	return 0, fmt.Errorf("tiempo inválido: %q", s)
+}
+
+func attr(e xml.StartElement, name string) (string, bool) {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+/*
+intervalo de un fragmento: <p> de TTML (begin/end/dur) y SRV3 (t/d en ms),
+<text> de SRV2 (t/d en ms) y SRV1 (start/dur en segundos)
+*/
+type cueTimes func(p xml.StartElement) (start, end time.Duration, err error)
+
+/*
+recorre los <p> (o <text>) del documento: su texto (incluido el de <span>/<s>) es el
+del fragmento y cada <br/> un salto de línea. El resto de espacios y
+saltos del XML cuentan como un espacio
+*/
+func parseXML(data []byte, times func(root xml.StartElement) cueTimes) ([]Cue, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		cues  []Cue
+		tf    cueTimes
+		cur   *Cue
+		lines []string
+		line  strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case tf == nil:
+				tf = times(t) // elemento raíz
+			case t.Name.Local == "p", t.Name.Local == "text":
+				start, end, err := tf(t)
+				if err != nil {
+					return nil, err
+				}
+				cur = &Cue{Start: start, End: end}
+				lines = lines[:0]
+				line.Reset()
+			case t.Name.Local == "br" && cur != nil:
+				if l := cleanLine(line.String()); l != "" {
+					lines = append(lines, l)
+				}
+				line.Reset()
+			}
+		case xml.CharData:
+			if cur != nil {
+				line.Write(t)
+			}
+		case xml.EndElement:
+			if (t.Name.Local == "p" || t.Name.Local == "text") && cur != nil {
+				if l := cleanLine(line.String()); l != "" {
+					lines = append(lines, l)
+				}
+				cur.Text = strings.Join(lines, "\n")
+				if cur.Text != "" { // SRV3 abre ventanas sin texto
+					cues = append(cues, *cur)
+				}
+				cur = nil
+			}
+		}
+	}
+	return cues, nil
+}
+
+func parseTTML(data []byte) ([]Cue, error) {
+	return parseXML(data, func(root xml.StartElement) cueTimes {
+		r := ttmlRates{frame: 30, tick: 1}
+		if v, ok := attr(root, "frameRate"); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+				r.frame = f
+			}
+		}
+		if v, ok := attr(root, "tickRate"); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+				r.tick = f
+			}
+		}
+		return func(p xml.StartElement) (start, end time.Duration, err error) {
+			if v, ok := attr(p, "begin"); ok {
+				if start, err = parseTTMLTime(v, r); err != nil {
+					return
+				}
+			}
+			if v, ok := attr(p, "end"); ok {
+				end, err = parseTTMLTime(v, r)
+				return
+			}
+			if v, ok := attr(p, "dur"); ok {
+				var d time.Duration
+				d, err = parseTTMLTime(v, r)
+				end = start + d
+				return
+			}
+			return start, start, fmt.Errorf("<p> sin end ni dur")
+		}
+	})
+}
+
+/* SRV3 (<p>) y SRV2 (<text>): t y d en milisegundos */
+func parseSRV3(data []byte) ([]Cue, error) {
+	return parseXML(data, func(xml.StartElement) cueTimes { return srvTimes("t", "d", time.Millisecond) })
+}
+
+/* SRV1: start y dur en segundos con decimales */
+func parseSRV1(data []byte) ([]Cue, error) {
+	return parseXML(data, func(xml.StartElement) cueTimes { return srvTimes("start", "dur", time.Second) })
+}
+
+func srvTimes(startAttr, durAttr string, unit time.Duration) cueTimes {
+	num := func(p xml.StartElement, name string) (time.Duration, error) {
+		v, _ := attr(p, name)
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("srv: %s inválido: %q", name, v)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+	return func(p xml.StartElement) (start, end time.Duration, err error) {
+		if start, err = num(p, startAttr); err != nil {
+			return
+		}
+		d, err := num(p, durAttr)
+		return start, start + d, err
+	}
+}
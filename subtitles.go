@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wolfbanner/youtube-downloader/subtitle"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                          entrega de subtítulos                             */
+/* -------------------------------------------------------------------------- */
+
+/*
+busca los subtítulos generados en dir (Titulo.<idioma>.<ext>); si hay uno
+lo devuelve tal cual, si hay varios los empaqueta en Titulo_subs.zip
+*/
+func collectSubtitles(dir, ext string) (string, error) {
+	var files []string
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, _ error) error {
+		if d != nil && !d.IsDir() && strings.EqualFold(filepath.Ext(p), "."+ext) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return "", errors.New("no hay subtítulos para los idiomas pedidos")
+	}
+
+	// "Titulo.en.srt" → "Titulo"
+	base := filepath.Base(files[0])
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	return bundleFiles(dir, files, base+"_subs.zip")
+}
+
+/*
+formatos que se piden a yt-dlp: los que el paquete subtitle sabe leer;
+si "best" trae otro se convierte con convertOtherSubs
+*/
+const subSourceFormats = "vtt/srt/ttml/srv3/json3/srv2/srv1/best"
+
+/* subtítulos legibles por el paquete subtitle en dir (sin subcarpetas) */
+func subtitleFiles(dir string) []string {
+	var files []string
+	for _, ext := range subtitle.Extensions() {
+		m, _ := filepath.Glob(filepath.Join(dir, "*."+ext))
+		files = append(files, m...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+/* lo que "best" puede traer y el paquete no lee, pero ffmpeg sí */
+var ffmpegSubExts = []string{"ass", "ssa", "lrc", "smi", "sami", "scc"}
+
+/*
+como --convert-subs de yt-dlp: pasa a VTT con ffmpeg los subtítulos que
+el paquete subtitle no sabe leer y borra el original
+*/
+func convertOtherSubs(dir string) error {
+	for _, ext := range ffmpegSubExts {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*."+ext))
+		for _, p := range matches {
+			out := strings.TrimSuffix(p, filepath.Ext(p)) + ".vtt"
+			if b, err := exec.Command("ffmpeg", "-v", "error", "-y", "-i", p, "-f", "webvtt", out).CombinedOutput(); err != nil {
+				os.Remove(out)
+				return fmt.Errorf("%s: ffmpeg: %v %s", filepath.Base(p), err, strings.TrimSpace(string(b)))
+			}
+			os.Remove(p)
+		}
+	}
+	return nil
+}
+
+func readSubtitle(path string) ([]subtitle.Cue, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cues, err := subtitle.Parse(b, subtitle.Detect(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return cues, nil
+}
+
+/*
+convierte en Go cada subtítulo descargado a Titulo.<idioma>.<ext>:
+limpia repeticiones, aplica el desfase pedido y borra el original
+*/
+func convertSubtitles(dir string, o jobOptions) error {
+	if err := convertOtherSubs(dir); err != nil {
+		return err
+	}
+	for _, p := range subtitleFiles(dir) {
+		cues, err := readSubtitle(p)
+		if err != nil {
+			return err
+		}
+		cues = subtitle.Merge(cues)
+		if o.SubShift != 0 {
+			cues = subtitle.Shift(cues, time.Duration(o.SubShift*float64(time.Second)))
+		}
+
+		out := strings.TrimSuffix(p, filepath.Ext(p)) + "." + o.SubFormat
+		tmp := out + ".tmp"
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		err = subtitle.Write(f, cues, o.SubFormat)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp, out)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if out != p {
+			os.Remove(p)
+		}
+	}
+	return nil
+}
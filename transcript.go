@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wolfbanner/youtube-downloader/subtitle"
+)
+
+/* -------------------------------------------------------------------------- */
+/*             transcripciones: subtítulos → TXT y Markdown                   */
+/* -------------------------------------------------------------------------- */
+
+const (
+	/* silencio que separa párrafos */
+	paragraphGap = 2 * time.Second
+	/* a partir de aquí se corta en el siguiente final de frase */
+	paragraphLen = 600
+)
+
+/*
+una línea por frase hablada. Los automáticos de YouTube repiten en cada
+bloque la línea anterior (subtítulos "rodantes") y añaden bloques de
+10 ms con el mismo texto: se descarta toda línea igual a la última emitida
+*/
+func transcriptLines(cues []subtitle.Cue) []subtitle.Cue {
+	var out []subtitle.Cue
+	last := ""
+	for _, c := range cues {
+		for _, l := range strings.Split(c.Text, "\n") {
+			l = strings.TrimSpace(l)
+			if l == "" || l == last {
+				continue
+			}
+			out = append(out, subtitle.Cue{Start: c.Start, End: c.End, Text: l})
+			last = l
+		}
+	}
+	return out
+}
+
+type paragraph struct {
+	Start time.Duration
+	Text  string
+}
+
+type transcriptSection struct {
+	Title      string // capítulo ("" sin capítulos)
+	Paragraphs []paragraph
+}
+
+func sentenceEnd(s string) bool {
+	return strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") ||
+		strings.HasSuffix(s, "!") || strings.HasSuffix(s, "…")
+}
+
+/* agrupa las líneas en párrafos y, si se piden, en secciones por capítulo */
+func buildTranscript(lines []subtitle.Cue, chapters []ytChapter) []transcriptSection {
+	chapters = append([]ytChapter(nil), chapters...)
+	sort.Slice(chapters, func(i, j int) bool { return chapters[i].StartTime < chapters[j].StartTime })
+
+	secs := []transcriptSection{{}}
+	next := 0 // siguiente capítulo por abrir
+	var cur *paragraph
+	var prevEnd time.Duration
+	flush := func() {
+		if cur != nil {
+			s := &secs[len(secs)-1]
+			s.Paragraphs = append(s.Paragraphs, *cur)
+			cur = nil
+		}
+	}
+	for _, l := range lines {
+		for next < len(chapters) && l.Start >= time.Duration(chapters[next].StartTime*float64(time.Second)) {
+			flush()
+			if len(secs) == 1 && len(secs[0].Paragraphs) == 0 {
+				secs[0].Title = chapters[next].Title
+			} else {
+				secs = append(secs, transcriptSection{Title: chapters[next].Title})
+			}
+			next++
+		}
+		if cur != nil && (l.Start-prevEnd > paragraphGap ||
+			(len(cur.Text) > paragraphLen && sentenceEnd(cur.Text))) {
+			flush()
+		}
+		if cur == nil {
+			cur = &paragraph{Start: l.Start, Text: l.Text}
+		} else {
+			cur.Text += " " + l.Text
+		}
+		prevEnd = l.End
+	}
+	flush()
+	return secs
+}
+
+/* [h:]mm:ss */
+func clockText(d time.Duration) string {
+	s := int(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
+
+func renderTranscriptTXT(title string, secs []transcriptSection, timestamps bool) string {
+	var b strings.Builder
+	if title != "" {
+		b.WriteString(title + "\n" + strings.Repeat("=", len([]rune(title))) + "\n\n")
+	}
+	for _, s := range secs {
+		if s.Title != "" {
+			b.WriteString(s.Title + "\n" + strings.Repeat("-", len([]rune(s.Title))) + "\n\n")
+		}
+		for _, p := range s.Paragraphs {
+			if timestamps {
+				b.WriteString("[" + clockText(p.Start) + "] ")
+			}
+			b.WriteString(p.Text + "\n\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func renderTranscriptMD(title string, secs []transcriptSection, timestamps bool) string {
+	var b strings.Builder
+	if title != "" {
+		b.WriteString("# " + title + "\n\n")
+	}
+	for _, s := range secs {
+		if s.Title != "" {
+			b.WriteString("## " + s.Title + "\n\n")
+		}
+		for _, p := range s.Paragraphs {
+			if timestamps {
+				b.WriteString("**[" + clockText(p.Start) + "]** ")
+			}
+			b.WriteString(p.Text + "\n\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+/* ------------------------------ job "transcript" -------------------------- */
+
+/* subtítulos sin convertir (no hace falta ffmpeg) */
+func transcriptArgs(o jobOptions) []string {
+	langs := o.SubLangs
+	if len(langs) == 0 {
+		langs = []string{"en"}
+	}
+	args := []string{"--skip-download", "--write-subs",
+		"--sub-langs", strings.Join(langs, ","), "--sub-format", subSourceFormats}
+	if o.AutoSubs {
+		args = append(args, "--write-auto-subs")
+	}
+	return args
+}
+
+/*
+metadatos del video al que pertenece el subtítulo path: yt-dlp lo nombra
+como el archivo del video ("filename" en metaFile) con .<idioma>.<ext>.
+Si no casa ninguno solo vale el único video del job
+*/
+func transcriptMeta(metas []ytMeta, path string) ytMeta {
+	sub := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	best, bestLen := ytMeta{}, 0
+	for _, m := range metas {
+		if m.Filename == "" {
+			continue
+		}
+		base := filepath.Base(m.Filename)
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+		if strings.HasPrefix(sub, base+".") && len(base) > bestLen {
+			best, bestLen = m, len(base)
+		}
+	}
+	if bestLen > 0 {
+		return best
+	}
+	for _, m := range metas {
+		if m.ID != metas[0].ID {
+			return ytMeta{}
+		}
+	}
+	if len(metas) > 0 {
+		return metas[0]
+	}
+	return ytMeta{}
+}
+
+/*
+convierte cada subtítulo descargado en dir a Titulo.<idioma>.txt y .md;
+devuelve el primer Markdown y los originales, que el job borra después de
+indexarlos
+*/
+func collectTranscripts(dir string, o jobOptions) (string, []string, error) {
+	if err := convertOtherSubs(dir); err != nil {
+		return "", nil, err
+	}
+	subs := subtitleFiles(dir)
+	if len(subs) == 0 {
+		return "", nil, errors.New("no hay subtítulos para los idiomas pedidos")
+	}
+
+	metas := readMeta(dir)
+	first := ""
+	for _, p := range subs {
+		cues, err := readSubtitle(p)
+		if err != nil {
+			return "", nil, err
+		}
+
+		base := strings.TrimSuffix(p, filepath.Ext(p))
+		m := transcriptMeta(metas, p)
+		var chapters []ytChapter
+		if o.ChapterHeadings {
+			chapters = m.Chapters
+		}
+		secs := buildTranscript(transcriptLines(cues), chapters)
+
+		txt := renderTranscriptTXT(m.Title, secs, o.Timestamps)
+		if err := os.WriteFile(base+".txt", []byte(txt), 0644); err != nil {
+			return "", nil, err
+		}
+		md := renderTranscriptMD(m.Title, secs, o.Timestamps)
+		if err := os.WriteFile(base+".md", []byte(md), 0644); err != nil {
+			return "", nil, err
+		}
+		if first == "" {
+			first = base + ".md"
+		}
+	}
+	return first, subs, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/* -------------------------------------------------------------------------- */
+/*         verificación con ffprobe de lo que yt-dlp dio por terminado        */
+/* -------------------------------------------------------------------------- */
+
+const maxVerifyRetries = 3
+
+var (
+	ffprobeOnce sync.Once
+	ffprobeOK   bool
+)
+
+/* sin ffprobe en el PATH la verificación se omite (se avisa una vez) */
+func canVerify() bool {
+	ffprobeOnce.Do(func() {
+		_, err := exec.LookPath("ffprobe")
+		ffprobeOK = err == nil
+		if !ffprobeOK {
+			log.Printf("ffprobe no encontrado: no se verificarán las descargas")
+		}
+	})
+	return ffprobeOK
+}
+
+/* margen para comparar duraciones: 2 s o el 1 % */
+func durationTolerance(expected float64) float64 {
+	return math.Max(2, expected*0.01)
+}
+
+/* duración esperada de path según los metadatos de yt-dlp (0 = desconocida) */
+func expectedDuration(metas []ytMeta, path string) float64 {
+	ids := map[string]bool{}
+	for _, m := range metas {
+		if m.FilePath != "" && filepath.Clean(m.FilePath) == filepath.Clean(path) && m.Duration > 0 {
+			return m.Duration
+		}
+		ids[m.ID] = true
+	}
+	if len(ids) == 1 {
+		for _, m := range metas {
+			if m.Duration > 0 {
+				return m.Duration
+			}
+		}
+	}
+	return 0
+}
+
+/*
+comprueba cada archivo de audio/video del job: que se pueda leer, que
+tenga las pistas pedidas y la duración del original. Devuelve los
+problemas encontrados (vacío = correcto) y, aparte, los avisos que no
+justifican reintentar: -S res:N es una preferencia y, si no hay nada
+de N o menos, yt-dlp baja una resolución mayor
+*/
+func verifyOutputs(opts jobOptions, dest string, files []outputFile) (issues, warnings []string) {
+	if !opts.isVideo() && opts.Media != "audio" {
+		return nil, nil
+	}
+	if !canVerify() {
+		return nil, nil
+	}
+
+	metas := readMeta(dest)
+	maxHeight, _ := strconv.Atoi(opts.Quality)
+	/* los recortes no duran lo que el original */
+	checkDuration := len(opts.Ranges) == 0
+
+	checked := 0
+	for _, f := range files {
+		if f.Role != "video" && f.Role != "audio" {
+			continue
+		}
+		checked++
+		p, err := probeFile(f.Path)
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("%s: ilegible (%v)", f.Name, err))
+			continue
+		}
+
+		v, hasVideo := p.video()
+		switch {
+		case opts.isVideo() && !hasVideo:
+			issues = append(issues, fmt.Sprintf("%s: sin pista de video", f.Name))
+		case opts.isVideo() && maxHeight > 0 && v.Height > maxHeight:
+			warnings = append(warnings, fmt.Sprintf("%s: %dp, no había nada de %dp o menos", f.Name, v.Height, maxHeight))
+		}
+		if !p.hasAudio() {
+			issues = append(issues, fmt.Sprintf("%s: sin pista de audio", f.Name))
+		}
+
+		got := p.duration()
+		if got <= 0 {
+			issues = append(issues, fmt.Sprintf("%s: duración desconocida", f.Name))
+			continue
+		}
+		if want := expectedDuration(metas, f.Path); checkDuration && want > 0 &&
+			math.Abs(got-want) > durationTolerance(want) {
+			issues = append(issues, fmt.Sprintf("%s: dura %.0f s, el original %.0f s", f.Name, got, want))
+		}
+	}
+	if checked == 0 {
+		issues = append(issues, fmt.Sprintf("no se generó ningún archivo de %s", opts.Media))
+	}
+	return issues, warnings
+}
+
+func setJobIssues(id string, issues []string, retries int) {
+	jobsMu.Lock()
+	if j, ok := jobs[id]; ok {
+		j.Issues = issues
+		j.Retries = retries
+	}
+	jobsMu.Unlock()
+}
+
+func jobRetries(id string) int {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	if j, ok := jobs[id]; ok {
+		return j.Retries
+	}
+	return 0
+}
+
+/* problemas en una línea, para el evento SSE "issues" */
+func jobIssues(id string) string {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	if j, ok := jobs[id]; ok {
+		return strings.Join(j.Issues, " | ")
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* -------------------------------------------------------------------------- */
+/*                 contenedor, códec, fps y HDR del video                     */
+/* -------------------------------------------------------------------------- */
+
+/* formato tal como viene en el JSON de yt-dlp */
+type ytFormat struct {
+	Ext            string
+	Vcodec, Acodec string
+	Height         int
+	Fps            float64
+	Abr            float64
+	DynamicRange   string `json:"dynamic_range"`
+}
+
+/* selector -f por contenedor; webm no admite fallback a otros códecs */
+var videoContainers = map[string]string{
+	"mp4":  "bv*[ext=mp4]+ba[ext=m4a]/b[ext=mp4]/bv*+ba/b",
+	"mkv":  "bv*+ba/b",
+	"webm": "bv*[ext=webm]+ba[ext=webm]/b[ext=webm]",
+}
+
+type videoCodec struct {
+	sortKey  string   // valor para -S vcodec:<x>
+	prefixes []string // vcodec de yt-dlp que lo identifican
+	inMP4    bool
+	inWebM   bool
+}
+
+var videoCodecs = map[string]videoCodec{
+	"h264": {sortKey: "h264", prefixes: []string{"avc1", "h264"}, inMP4: true},
+	"vp9":  {sortKey: "vp9", prefixes: []string{"vp9", "vp09"}, inWebM: true},
+	"av1":  {sortKey: "av01", prefixes: []string{"av01"}, inMP4: true, inWebM: true},
+}
+
+func (c videoCodec) matches(vcodec string) bool {
+	for _, p := range c.prefixes {
+		if strings.HasPrefix(vcodec, p) {
+			return true
+		}
+	}
+	return false
+}
+
+/* comprueba que la combinación contenedor / códec / HDR es posible */
+func validateVideoOptions(o jobOptions) error {
+	if _, ok := videoContainers[o.Container]; !ok {
+		return fmt.Errorf("contenedor no soportado: %s", o.Container)
+	}
+	if o.VideoCodec != "" {
+		c, ok := videoCodecs[o.VideoCodec]
+		if !ok {
+			return fmt.Errorf("códec de video no soportado: %s", o.VideoCodec)
+		}
+		if (o.Container == "mp4" && !c.inMP4) || (o.Container == "webm" && !c.inWebM) {
+			return fmt.Errorf("%s no es compatible con %s", o.VideoCodec, o.Container)
+		}
+	}
+	switch o.HDR {
+	case "", "prefer", "avoid":
+	default:
+		return fmt.Errorf("preferencia HDR inválida: %s", o.HDR)
+	}
+	return nil
+}
+
+/* argumentos de yt-dlp para un job de video: selector + expresión -S */
+func videoArgs(o jobOptions) []string {
+	var sortKeys []string
+	if o.Quality != "" {
+		sortKeys = append(sortKeys, "res:"+o.Quality)
+	}
+	if o.MaxFPS > 0 {
+		sortKeys = append(sortKeys, "fps:"+strconv.Itoa(o.MaxFPS))
+	}
+	switch o.HDR {
+	case "prefer":
+		sortKeys = append(sortKeys, "hdr")
+	case "avoid":
+		sortKeys = append(sortKeys, "hdr:sdr")
+	}
+	if c, ok := videoCodecs[o.VideoCodec]; ok {
+		sortKeys = append(sortKeys, "vcodec:"+c.sortKey)
+	}
+
+	args := []string{"-f", videoContainers[o.Container]}
+	if len(sortKeys) > 0 {
+		args = append(args, "-S", strings.Join(sortKeys, ","))
+	}
+	return append(args, "--merge-output-format", o.Container)
+}
+
+/* resoluciones alcanzables con cada contenedor y cada códec (para la UI) */
+type videoReach struct {
+	Containers map[string][]string `json:"containers"`
+	Codecs     map[string][]string `json:"codecs"`
+	MaxFPS     int                 `json:"max_fps"`
+	HDR        bool                `json:"hdr"`
+}
+
+func videoReachability(formats []ytFormat) videoReach {
+	cont := map[string]map[int]bool{}
+	codecs := map[string]map[int]bool{}
+	add := func(m map[string]map[int]bool, key string, h int) {
+		if m[key] == nil {
+			m[key] = map[int]bool{}
+		}
+		m[key][h] = true
+	}
+
+	var r videoReach
+	for _, f := range formats {
+		if f.Vcodec == "none" || f.Height <= 0 {
+			continue
+		}
+		add(cont, "mkv", f.Height)
+		if f.Ext == "mp4" || f.Ext == "webm" {
+			add(cont, f.Ext, f.Height)
+		}
+		for name, c := range videoCodecs {
+			if c.matches(f.Vcodec) {
+				add(codecs, name, f.Height)
+			}
+		}
+		if int(f.Fps) > r.MaxFPS {
+			r.MaxFPS = int(f.Fps)
+		}
+		if f.DynamicRange != "" && f.DynamicRange != "SDR" {
+			r.HDR = true
+		}
+	}
+
+	heights := func(set map[int]bool) []string {
+		hs := make([]int, 0, len(set))
+		for h := range set {
+			hs = append(hs, h)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(hs)))
+		out := make([]string, len(hs))
+		for i, h := range hs {
+			out[i] = strconv.Itoa(h)
+		}
+		return out
+	}
+	r.Containers = map[string][]string{}
+	for k, set := range cont {
+		r.Containers[k] = heights(set)
+	}
+	r.Codecs = map[string][]string{}
+	for k, set := range codecs {
+		r.Codecs[k] = heights(set)
+	}
+	return r
+}